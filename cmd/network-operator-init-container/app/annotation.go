@@ -0,0 +1,134 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/handshake"
+	"github.com/Mellanox/network-operator-init-container/pkg/tracing"
+)
+
+// setSafeLoadAnnotation sets the gate for safeDriverLoading feature and additional gates
+// on the handshake object, the Node object or the per-node ConfigMap, the existing annotation is inspected
+// and taken over. The gates are set with server-side apply with resourceVersion of the inspected object
+// as a precondition, the object is read and inspected again if it has been changed concurrently
+func setSafeLoadAnnotation(ctx context.Context, logger logr.Logger, k8sClient client.Client,
+	opts *options.Options, cfg configPgk.SafeDriverLoadConfig, handshakeObj client.Object) error {
+	logger = logger.WithValues("node", opts.NodeName, "object", handshakeObjectDescription(handshakeObj))
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node := &corev1.Node{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: opts.NodeName}, node); err != nil {
+			logger.Error(err, "failed to read node object from the API")
+			return err
+		}
+		obj := handshakeObj.DeepCopyObject().(client.Object)
+		if isNodeHandshakeObject(obj) {
+			obj = node
+		} else if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			if !apiErrors.IsNotFound(err) {
+				logger.Error(err, "failed to read handshake object from the API")
+				return err
+			}
+			// the object is created by server-side apply
+		}
+		labels, annotations := gatesMetadata(additionalGates(cfg))
+		for k, v := range handshakeObj.GetLabels() {
+			labels[k] = v
+		}
+		if cfg.GetGateType() == configPgk.GateTypeLabel {
+			labels[cfg.Label] = handshake.LabelValueWaiting
+		}
+		if cfg.Annotation != "" {
+			annotationValue, err := newSafeLoadAnnotationValue(ctx, logger, k8sClient, opts, cfg, node, obj)
+			if err != nil {
+				return err
+			}
+			annotations[cfg.Annotation] = annotationValue
+		}
+		err := setObjectMetadata(ctx, k8sClient, fieldManager, obj, labels, annotations)
+		if apiErrors.IsConflict(err) {
+			logger.V(1).Info("handshake object has been changed, retry")
+		}
+		return err
+	})
+	if err != nil {
+		logger.Error(err, "unable to set annotation")
+		return err
+	}
+	return nil
+}
+
+// newSafeLoadAnnotationValue returns the value for the annotation, the existing annotation
+// on the handshake object is inspected and reset or taken over, an error is returned
+// if the annotation is owned by another running pod
+func newSafeLoadAnnotationValue(ctx context.Context, logger logr.Logger, k8sClient client.Client,
+	opts *options.Options, cfg configPgk.SafeDriverLoadConfig, node *corev1.Node,
+	handshakeObj metav1.Object) (string, error) {
+	var owner *handshake.Owner
+	if opts.PodName != "" {
+		owner = &handshake.Owner{Namespace: opts.PodNamespace, Name: opts.PodName, UID: opts.PodUID}
+	}
+	if value, exist := handshakeObj.GetAnnotations()[cfg.Annotation]; exist {
+		reason, stale, err := inspectExistingAnnotation(ctx, k8sClient, node, value, owner)
+		if err != nil {
+			logger.Error(err, "failed to inspect existing annotation")
+			return "", err
+		}
+		switch {
+		case reason == annotationReasonOwnerTerminating || reason == annotationReasonOwnerCompleted:
+			logger.Info("owner pod of the annotation is terminating or completed, reset stale annotation",
+				"reason", reason, "value", value)
+		case stale:
+			logger.Info("reset stale annotation", "reason", reason, "value", value)
+		case reason == annotationReasonOwnerExists:
+			// the owner is still waiting for the gate, the annotation is not taken from it
+			err := fmt.Errorf("annotation %s is owned by another running pod, reason: %s, value: %s",
+				cfg.Annotation, reason, value)
+			logger.Error(err, "can't set annotation")
+			return "", err
+		default:
+			logger.Info("take over existing annotation", "reason", reason, "value", value)
+		}
+	}
+	payload := &handshake.Payload{
+		TraceContext: tracing.InjectContext(ctx),
+		BootID:       node.Status.NodeInfo.BootID,
+		Owner:        owner,
+	}
+	if heartbeatTimeout := cfg.GetHeartbeatTimeout(); heartbeatTimeout > 0 {
+		payload.Heartbeat = &metav1.Time{Time: time.Now()}
+		// round up, the timeout in the payload should never be shorter than the configured one
+		payload.HeartbeatTimeoutSeconds = int64(math.Ceil(heartbeatTimeout.Seconds()))
+	}
+	annotationValue, err := payload.Encode()
+	if err != nil {
+		logger.Error(err, "failed to encode annotation payload")
+		return "", err
+	}
+	return annotationValue, nil
+}
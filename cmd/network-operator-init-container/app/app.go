@@ -15,34 +15,50 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 	cliflag "k8s.io/component-base/cli/flag"
 	"k8s.io/component-base/term"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlConfig "sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	// register json format for logger
 	_ "k8s.io/component-base/logs/json/register"
 
 	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	"github.com/Mellanox/network-operator-init-container/pkg/condition"
 	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/metrics"
+	"github.com/Mellanox/network-operator-init-container/pkg/retry"
+	"github.com/Mellanox/network-operator-init-container/pkg/status"
+	"github.com/Mellanox/network-operator-init-container/pkg/tracing"
 	"github.com/Mellanox/network-operator-init-container/pkg/utils/version"
 )
 
+const (
+	componentName = "network-operator-init-container"
+
+	tracingShutdownTimeout = 5 * time.Second
+)
+
 // NewNetworkOperatorInitContainerCommand creates a new command
 func NewNetworkOperatorInitContainerCommand() *cobra.Command {
 	opts := options.New()
@@ -54,6 +70,7 @@ func NewNetworkOperatorInitContainerCommand() *cobra.Command {
 		SilenceUsage: true,
 		Version:      version.GetVersionString(),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.ApplyEnvFallbacks()
 			if err := opts.Validate(); err != nil {
 				return fmt.Errorf("invalid config: %w", err)
 			}
@@ -77,7 +94,7 @@ func NewNetworkOperatorInitContainerCommand() *cobra.Command {
 	sharedFS := cliflag.NamedFlagSets{}
 	opts.AddNamedFlagSets(&sharedFS)
 
-	cmdFS := cmd.PersistentFlags()
+	cmdFS := cmd.Flags()
 	for _, f := range sharedFS.FlagSets {
 		cmdFS.AddFlagSet(f)
 	}
@@ -85,20 +102,72 @@ func NewNetworkOperatorInitContainerCommand() *cobra.Command {
 	cols, _, _ := term.TerminalSize(cmd.OutOrStdout())
 	cliflag.SetUsageAndHelpFunc(cmd, sharedFS, cols)
 
+	cmd.AddCommand(newRestoreCommand(ctx))
+
 	return cmd
 }
 
 // RunNetworkOperatorInitContainer runs init container main loop
-func RunNetworkOperatorInitContainer(ctx context.Context, config *rest.Config, opts *options.Options) error {
+func RunNetworkOperatorInitContainer(ctx context.Context, config *rest.Config, opts *options.Options) (retErr error) {
 	logger := logr.FromContextOrDiscard(ctx)
 	ctx, cFunc := context.WithCancel(ctx)
 	defer cFunc()
 	logger.Info("start network-operator-init-container",
 		"Options", opts, "Version", version.GetVersionString())
 	ctrl.SetLogger(logger)
+	config = kubeAPIConfig(config, opts)
+	defer func() { writeTerminationMessage(logger, opts, retErr) }()
+	metrics.Register()
+
+	// the delay should be before any request to the API server
+	if err := startupDelay(ctx, logger, opts.StartupJitter); err != nil {
+		return err
+	}
+
+	// the node name is required to configure the manager, so the pod is read with a separate client
+	if opts.NodeName == "" {
+		podClient, err := client.NewWithWatch(config, client.Options{})
+		if err != nil {
+			logger.Error(err, "failed to create k8sClient client")
+			return err
+		}
+		if err := resolveNodeNameFromPod(ctx, logger, retry.Client(metrics.InstrumentClient(podClient),
+			opts.APIRetryPolicy(), logger), opts); err != nil {
+			return err
+		}
+	}
+	logger.Info("node and pod identity", "node", opts.NodeName, "podName", opts.PodName,
+		"podNamespace", opts.PodNamespace, "podUID", opts.PodUID, "sources", opts.IdentitySources)
+
+	tracker := status.NewTracker(opts.NodeName)
+
+	shutdownTracing, err := tracing.Setup(ctx, opts.TracingEndpoint, opts.NodeName)
+	if err != nil {
+		logger.Error(err, "unable to set up tracing")
+		return err
+	}
+	defer func() {
+		shutdownCtx, shutdownCFunc := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer shutdownCFunc()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Error(err, "failed to flush traces")
+		}
+	}()
+	ctx, span := tracing.Tracer().Start(ctx, "RunNetworkOperatorInitContainer",
+		trace.WithAttributes(semconv.K8SNodeName(opts.NodeName)))
+	defer func() {
+		recordSpanError(span, retErr)
+		span.End()
+	}()
+
+	phases := newPhaseReporter(ctx, logger, tracker)
+	defer func() { phases.finish(retErr) }()
 
 	mgr, err := ctrl.NewManager(config, ctrl.Options{
-		Metrics: metricsserver.Options{BindAddress: "0"},
+		Metrics:                metricsserver.Options{BindAddress: opts.MetricsBindAddress},
+		HealthProbeBindAddress: opts.HealthProbeBindAddress,
+		// RunNetworkOperatorInitContainer can be called multiple times in the same process
+		Controller: ctrlConfig.Controller{SkipNameValidation: ptr.To(true)},
 		Cache: cache.Options{
 			ByObject: map[client.Object]cache.ByObject{
 				&corev1.Node{}: {Field: fields.ParseSelectorOrDie(
@@ -109,13 +178,42 @@ func RunNetworkOperatorInitContainer(ctx context.Context, config *rest.Config, o
 		return err
 	}
 
-	k8sClient, err := client.New(config,
+	nodeWatch := &nodeWatchSyncedCheck{}
+	if err := addHealthChecks(mgr, tracker, nodeWatch); err != nil {
+		logger.Error(err, "unable to set up health checks")
+		return err
+	}
+	if err := addStatusServer(mgr, opts.StatusBindAddress, tracker); err != nil {
+		logger.Error(err, "unable to set up status server")
+		return err
+	}
+
+	k8sClientWithWatch, err := client.NewWithWatch(config,
 		client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
 	if err != nil {
 		logger.Error(err, "failed to create k8sClient client")
 		return err
 	}
+	k8sClient := retry.Client(metrics.InstrumentClient(k8sClientWithWatch), opts.APIRetryPolicy(), logger)
+	recorder := newEventRecorder(mgr.GetEventRecorderFor(componentName), opts)
+
+	errCh := make(chan error, 1)
+
+	// the manager is started before the Node is annotated to serve metrics and health probes,
+	// the Node controller is added to the running manager after the annotation is set
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := mgr.Start(ctx); err != nil {
+			logger.Error(err, "problem running manager")
+			writeCh(errCh, err)
+		}
+	}()
+	defer wg.Wait()
+	defer cFunc()
 
+	phases.set(phaseLoadConfig)
 	confConfigMap := &corev1.ConfigMap{}
 
 	err = k8sClient.Get(ctx, client.ObjectKey{
@@ -129,84 +227,271 @@ func RunNetworkOperatorInitContainer(ctx context.Context, config *rest.Config, o
 	}
 
 	initContCfg, err := configPgk.Load(confConfigMap.Data[opts.ConfigMapKey])
+	metrics.ConfigLoaded(err)
 	if err != nil {
 		logger.Error(err, "failed to read configuration")
 		return err
 	}
+	tracker.SetConfigLoaded()
 	logger.Info("network-operator-init-container configuration", "config", initContCfg.String())
 
+	phases.set(phaseCheckPermissions)
+	if err := checkPermissions(ctx, logger, k8sClient,
+		requiredPermissions(k8sClient, opts, initContCfg)); err != nil {
+		return err
+	}
+
+	if initContCfg.WaitForNode.Enable {
+		phases.set(phaseWaitForNode)
+		if err := waitForNode(ctx, logger, k8sClient, opts.NodeName, initContCfg.WaitForNode); err != nil {
+			return err
+		}
+	}
+
+	if initContCfg.NodeLock.Enable {
+		phases.set(phaseAcquireNodeLock)
+		lockedCtx, releaseNodeLock, err := acquireNodeLock(ctx, logger, k8sClient, opts, initContCfg.NodeLock)
+		if err != nil {
+			return err
+		}
+		defer releaseNodeLock()
+		// the following steps are canceled if the lock is lost, the loss of the lock is reported as the error
+		defer func() {
+			if cause := context.Cause(lockedCtx); retErr != nil && errors.Is(cause, errNodeLockLost) {
+				retErr = cause
+			}
+		}()
+		ctx = lockedCtx
+	}
+
+	if initContCfg.DeviceInventory.Enable {
+		phases.set(phaseDeviceInventory)
+		devices, err := runDeviceInventory(ctx, logger, k8sClient, opts, initContCfg.DeviceInventory)
+		if err != nil {
+			return err
+		}
+		if len(devices) == 0 {
+			logger.Info("no NVIDIA/Mellanox network devices found on the node, exit", "node", opts.NodeName)
+			return nil
+		}
+	}
+
+	phases.set(phasePreflight)
+	if err := runPreflight(ctx, logger, k8sClient, recorder,
+		opts, initContCfg.Preflight); err != nil {
+		return err
+	}
+
+	if initContCfg.SRIOV.Enable {
+		phases.set(phaseSRIOVSnapshot)
+		if err := runSRIOVSnapshot(logger, opts, initContCfg.SRIOV); err != nil {
+			return err
+		}
+	}
+
+	if initContCfg.WaitForPreviousPod.Enable {
+		phases.set(phaseWaitForPreviousPod)
+		if err := waitForPreviousPod(ctx, logger, k8sClient, recorder,
+			opts, initContCfg.WaitForPreviousPod); err != nil {
+			return err
+		}
+	}
+
 	if !initContCfg.SafeDriverLoad.Enable {
 		logger.Info("safe driver loading is disabled, exit")
 		return nil
 	}
 
-	errCh := make(chan error, 1)
+	cordoned := false
+	if initContCfg.Drain.Enable {
+		phases.set(phaseDrain)
+		if cordoned, err = runDrain(ctx, logger, k8sClient, opts, initContCfg.Drain); err != nil {
+			return err
+		}
+	}
 
-	if err = (&NodeReconciler{
+	reconciler := &NodeReconciler{
 		ErrCh:              errCh,
 		SafeLoadAnnotation: initContCfg.SafeDriverLoad.Annotation,
+		SafeLoadLabel:      safeLoadLabel(initContCfg.SafeDriverLoad),
+		Gates:              additionalGates(initContCfg.SafeDriverLoad),
 		Client:             mgr.GetClient(),
 		Scheme:             mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		logger.Error(err, "unable to create controller", "controller", "Node")
-		return err
+	}
+	handshakeObj := newHandshakeObject(opts, initContCfg.SafeDriverLoad)
+	waitPhase := phaseWait
+	switch initContCfg.SafeDriverLoad.GetWaitMode() {
+	case configPgk.WaitModeCondition:
+		waitPhase = phaseWaitCondition
+		if reconciler.Release, reconciler.Failure, err = compileCondition(
+			initContCfg.SafeDriverLoad.Condition); err != nil {
+			logger.Error(err, "failed to compile condition")
+			return err
+		}
+	case configPgk.WaitModeNodeMaintenance:
+		waitPhase = phaseWaitNodeMaintenance
+		phases.set(phaseCreateNodeMaintenance)
+		nodeMaintenance := newNodeMaintenance(opts.NodeName, initContCfg.SafeDriverLoad.NodeMaintenance)
+		if err := createNodeMaintenance(ctx, logger, k8sClient, nodeMaintenance); err != nil {
+			return err
+		}
+		defer deleteNodeMaintenance(logger, k8sClient, nodeMaintenance)
+		if err := mgr.Add(&nodeMaintenanceWaiter{
+			Client:   k8sClient,
+			Object:   nodeMaintenance,
+			Interval: nodeMaintenancePollInterval,
+			ErrCh:    errCh,
+			Logger:   logger,
+		}); err != nil {
+			logger.Error(err, "unable to start NodeMaintenance waiter")
+			return err
+		}
+	default:
+		phases.set(phaseSetAnnotation)
+		if err := setSafeLoadAnnotation(ctx, logger, k8sClient, opts, initContCfg.SafeDriverLoad,
+			handshakeObj); err != nil {
+			return err
+		}
 	}
 
-	node := &corev1.Node{}
-	err = k8sClient.Get(ctx, types.NamespacedName{Name: opts.NodeName}, node)
-	if err != nil {
-		logger.Error(err, "failed to read node object from the API", "node", opts.NodeName)
-		return err
+	// the Node object is watched with the cache of the manager, the ConfigMap is watched with a separate cache
+	// which is restricted to the namespace and the name of the ConfigMap
+	heartbeatReader := client.Reader(mgr.GetClient())
+	switch {
+	case waitPhase == phaseWaitNodeMaintenance:
+	case !isNodeHandshakeObject(handshakeObj):
+		heartbeatReader = k8sClient
+		cmCache, err := newHandshakeConfigMapCache(mgr, handshakeObj)
+		if err != nil {
+			logger.Error(err, "unable to create handshake ConfigMap cache")
+			return err
+		}
+		if err := mgr.Add(&handshakeConfigMapWaiter{
+			Cache:   cmCache,
+			Object:  handshakeObj,
+			Pending: reconciler.pendingGates,
+			ErrCh:   errCh,
+			Logger:  logger,
+		}); err != nil {
+			logger.Error(err, "unable to start handshake ConfigMap waiter")
+			return err
+		}
+	default:
+		if err = reconciler.SetupWithManager(mgr); err != nil {
+			logger.Error(err, "unable to create controller", "controller", "Node")
+			return err
+		}
+		// the informer is already created by the controller
+		informer, err := mgr.GetCache().GetInformer(ctx, &corev1.Node{}, cache.BlockUntilSynced(false))
+		if err != nil {
+			logger.Error(err, "unable to get Node informer")
+			return err
+		}
+		nodeWatch.SetInformer(informer)
 	}
-	err = k8sClient.Patch(ctx, node, client.RawPatch(
-		types.MergePatchType, []byte(
-			fmt.Sprintf(`{"metadata":{"annotations":{%q: %q}}}`,
-				initContCfg.SafeDriverLoad.Annotation, "true"))))
-	if err != nil {
-		logger.Error(err, "unable to set annotation for node", "node", opts.NodeName)
-		return err
+
+	if waitPhase == phaseWait && initContCfg.SafeDriverLoad.Annotation != "" &&
+		initContCfg.SafeDriverLoad.HeartbeatInterval.Duration > 0 {
+		if err := mgr.Add(&heartbeat{
+			Reader:     heartbeatReader,
+			Writer:     k8sClient,
+			Object:     handshakeObj,
+			Annotation: initContCfg.SafeDriverLoad.Annotation,
+			Interval:   initContCfg.SafeDriverLoad.HeartbeatInterval.Duration,
+			Logger:     logger,
+		}); err != nil {
+			logger.Error(err, "unable to start heartbeat")
+			return err
+		}
 	}
 
-	logger.Info("wait for annotation to be removed",
-		"annotation", initContCfg.SafeDriverLoad.Annotation, "node", opts.NodeName)
+	phases.set(waitPhase)
+	waitStart := time.Now()
+	switch waitPhase {
+	case phaseWaitCondition:
+		tracker.SetWaiting("")
+		logger.Info("wait for condition", "release", initContCfg.SafeDriverLoad.Condition.Release,
+			"failure", initContCfg.SafeDriverLoad.Condition.Failure, "node", opts.NodeName)
+	case phaseWaitNodeMaintenance:
+		tracker.SetWaiting("")
+		logger.Info("wait for NodeMaintenance object to become ready", "node", opts.NodeName)
+	default:
+		tracker.SetWaiting(initContCfg.SafeDriverLoad.Annotation)
+		logger.Info("wait for annotation to be removed",
+			"annotation", initContCfg.SafeDriverLoad.Annotation, "label", reconciler.SafeLoadLabel,
+			"gates", reconciler.Gates, "object", handshakeObjectDescription(handshakeObj))
+	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := mgr.Start(ctx); err != nil {
-			logger.Error(err, "problem running manager")
-			writeCh(errCh, err)
-		}
-	}()
-	defer wg.Wait()
 	select {
 	case <-ctx.Done():
 		return fmt.Errorf("waiting canceled")
 	case err = <-errCh:
-		cFunc()
+	}
+	if err != nil {
 		return err
 	}
+	metrics.WaitDuration.Observe(time.Since(waitStart).Seconds())
+
+	if initContCfg.WaitForWorkloads.Enable {
+		phases.set(phaseWaitForWorkloads)
+		if err := waitForWorkloads(ctx, logger, k8sClient, recorder,
+			opts, initContCfg.WaitForWorkloads); err != nil {
+			return err
+		}
+	}
+
+	phases.set(phaseRelease)
+	if cordoned {
+		if err := setNodeUnschedulable(ctx, k8sClient, opts.NodeName, false); err != nil {
+			logger.Error(err, "failed to uncordon the node", "node", opts.NodeName)
+		} else {
+			logger.Info("node uncordoned", "node", opts.NodeName)
+		}
+	}
+	if reconciler.SafeLoadLabel != "" && reconciler.SafeLoadAnnotation != "" {
+		// in the label gate type the annotation contains details of the wait and is removed by the container
+		if err := removeAnnotation(ctx, k8sClient, handshakeObj, reconciler.SafeLoadAnnotation); err != nil {
+			logger.Error(err, "failed to remove annotation", "annotation", reconciler.SafeLoadAnnotation)
+		}
+	}
+	return nil
 }
 
 // NodeReconciler reconciles Node object
 type NodeReconciler struct {
 	ErrCh              chan error
 	SafeLoadAnnotation string
+	// if set, the label is used as the gate instead of the annotation
+	SafeLoadLabel string
+	// additional gates, the reconciler waits until the annotation and all gates are removed
+	Gates []Gate
+	// if set, the reconciler waits for the expression to become true instead of the annotation removal
+	Release *condition.Expression
+	// if set, the reconciler fails when the expression becomes true
+	Failure *condition.Expression
 	client.Client
 	Scheme *runtime.Scheme
 }
 
 // Reconcile contains logic to sync Node object
 func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	reqLog := log.FromContext(ctx).WithValues("annotation", r.SafeLoadAnnotation)
+	reqLog := log.FromContext(ctx).WithValues("annotation", r.SafeLoadAnnotation, "label", r.SafeLoadLabel)
 
 	node := &corev1.Node{}
 	err := r.Client.Get(ctx, req.NamespacedName, node)
 	if err != nil {
 		if apiErrors.IsNotFound(err) {
-			reqLog.Info("Node object not found, exit")
+			// the gates are removed together with the Node object, the init container fails and,
+			// if waitForNode is enabled, waits for the Node object to be recreated after the restart
+			err := fmt.Errorf("node object %s was deleted while waiting for the gate", req.Name)
+			reqLog.Error(err, "Node object not found, exit")
 			writeCh(r.ErrCh, err)
+			return ctrl.Result{}, nil
+		}
+		if retry.IsTransient(err) {
+			// the request is retried by the controller with backoff
+			metrics.APIRetries.WithLabelValues("get").Inc()
+			reqLog.Info("transient error on reading Node object, retry", "error", err.Error())
 			return ctrl.Result{}, err
 		}
 		reqLog.Error(err, "failed to get Node object from the cache")
@@ -214,16 +499,82 @@ func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, err
 	}
 
-	if node.GetAnnotations()[r.SafeLoadAnnotation] == "" {
+	if r.Release != nil {
+		return r.reconcileCondition(ctx, node)
+	}
+
+	pending := r.pendingGates(node)
+	if len(pending) == 0 {
 		reqLog.Info("annotation removed, unblock loading")
 		writeCh(r.ErrCh, nil)
 		return ctrl.Result{}, nil
 	}
-	reqLog.Info("annotation still present, waiting")
+	reqLog.Info("annotation still present, waiting", "pending", pending)
 
 	return ctrl.Result{RequeueAfter: time.Second * 5}, nil
 }
 
+// pendingGates returns the gate and the additional gates which are still set on the Node object
+func (r *NodeReconciler) pendingGates(obj metav1.Object) []string {
+	gate := Gate{Name: r.SafeLoadAnnotation}
+	if r.SafeLoadLabel != "" {
+		gate = Gate{Label: true, Name: r.SafeLoadLabel}
+	}
+	pending := []string{}
+	for _, g := range append([]Gate{gate}, r.Gates...) {
+		if g.IsSet(obj) {
+			pending = append(pending, g.String())
+		}
+	}
+	return pending
+}
+
+// reconcileCondition evaluates the release and the failure expressions against the Node object,
+// evaluation errors are not fatal because the Node object may not have required fields yet
+func (r *NodeReconciler) reconcileCondition(ctx context.Context, node *corev1.Node) (ctrl.Result, error) {
+	reqLog := log.FromContext(ctx).WithValues("release", r.Release.String())
+	if r.Failure != nil {
+		failed, err := r.Failure.Evaluate(node)
+		if err != nil {
+			reqLog.Info("failed to evaluate failure expression", "failure", r.Failure.String(), "error", err.Error())
+		}
+		if failed {
+			err := fmt.Errorf("failure expression %q is true", r.Failure.String())
+			reqLog.Error(err, "failure condition detected")
+			writeCh(r.ErrCh, err)
+			return ctrl.Result{}, nil
+		}
+	}
+	released, err := r.Release.Evaluate(node)
+	if err != nil {
+		reqLog.Info("failed to evaluate release expression", "error", err.Error())
+	}
+	if released {
+		reqLog.Info("release expression is true, unblock loading")
+		writeCh(r.ErrCh, nil)
+		return ctrl.Result{}, nil
+	}
+	reqLog.Info("release expression is false, waiting")
+
+	return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+}
+
+// compileCondition compiles the release and the failure expressions
+func compileCondition(cfg configPgk.ConditionConfig) (*condition.Expression, *condition.Expression, error) {
+	release, err := condition.Compile(cfg.Release)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Failure == "" {
+		return release, nil, nil
+	}
+	failure, err := condition.Compile(cfg.Failure)
+	if err != nil {
+		return nil, nil, err
+	}
+	return release, failure, nil
+}
+
 func writeCh(ch chan error, err error) {
 	select {
 	case ch <- err:
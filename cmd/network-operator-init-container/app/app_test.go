@@ -16,29 +16,54 @@ package app_test
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+	policyv1 "k8s.io/api/policy/v1"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app"
 	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
 	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/handshake"
+	"github.com/Mellanox/network-operator-init-container/pkg/inventory"
+	"github.com/Mellanox/network-operator-init-container/pkg/preflight"
+	"github.com/Mellanox/network-operator-init-container/pkg/status"
 )
 
 const (
-	testConfigMapName      = "test"
-	testConfigMapNamespace = "default"
-	testConfigMapKey       = "conf"
-	testNodeName           = "node1"
-	testAnnotation         = "foo.bar/spam"
+	testConfigMapName        = "test"
+	testConfigMapNamespace   = "default"
+	testConfigMapKey         = "conf"
+	testNodeName             = "node1"
+	testAnnotation           = "foo.bar/spam"
+	testPreflightAnnotation  = "foo.bar/preflight"
+	testLabel                = "foo.bar/label"
+	testAdditionalAnnotation = "foo.bar/eggs"
 )
 
 func createNode(name string) *corev1.Node {
@@ -57,11 +82,103 @@ func createConfig(cfg configPgk.Config) {
 	ExpectWithOffset(1, err).NotTo(HaveOccurred())
 }
 
+// setNodeBootID sets boot ID in the status of the Node object
+func setNodeBootID(name string, bootID string) {
+	node := &corev1.Node{}
+	ExpectWithOffset(1, k8sClient.Get(ctx, types.NamespacedName{Name: name}, node)).NotTo(HaveOccurred())
+	node.Status.NodeInfo.BootID = bootID
+	ExpectWithOffset(1, k8sClient.Status().Update(ctx, node)).NotTo(HaveOccurred())
+}
+
+// setNodeAnnotation sets the annotation with the payload on the Node object
+func setNodeAnnotation(name string, payload *handshake.Payload) {
+	value, err := payload.Encode()
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	ExpectWithOffset(1, k8sClient.Patch(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}},
+		client.RawPatch(types.MergePatchType, []byte(
+			fmt.Sprintf(`{"metadata":{"annotations":{%q: %q}}}`, testAnnotation, value))))).NotTo(HaveOccurred())
+}
+
+// createPod creates pod on the Node, the pod requests resources from the list
+func createPod(name string, nodeName string, resources []corev1.ResourceName,
+	owner *metav1.OwnerReference) *corev1.Pod {
+	limits := corev1.ResourceList{}
+	for _, r := range resources {
+		limits[r] = resource.MustParse("1")
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testConfigMapNamespace, Labels: map[string]string{"app": name}},
+		Spec: corev1.PodSpec{NodeName: nodeName, Containers: []corev1.Container{{
+			Name: "test", Image: "test", Resources: corev1.ResourceRequirements{Limits: limits}}}},
+	}
+	if owner != nil {
+		pod.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	ExpectWithOffset(1, k8sClient.Create(ctx, pod)).NotTo(HaveOccurred())
+	pod.Status.Phase = corev1.PodRunning
+	ExpectWithOffset(1, k8sClient.Status().Update(ctx, pod)).NotTo(HaveOccurred())
+	DeferCleanup(func() {
+		err := k8sClient.Delete(ctx, pod, client.GracePeriodSeconds(0))
+		if !apiErrors.IsNotFound(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+	return pod
+}
+
+// testCollector is a stand-in for OTLP/HTTP collector which records names of received spans
+type testCollector struct {
+	*httptest.Server
+	lock  sync.Mutex
+	spans []string
+}
+
+func newTestCollector() *testCollector {
+	c := &testCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer GinkgoRecover()
+		data, err := io.ReadAll(r.Body)
+		Expect(err).NotTo(HaveOccurred())
+		req := &collectortracev1.ExportTraceServiceRequest{}
+		Expect(proto.Unmarshal(data, req)).NotTo(HaveOccurred())
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		for _, rs := range req.GetResourceSpans() {
+			for _, ss := range rs.GetScopeSpans() {
+				for _, span := range ss.GetSpans() {
+					c.spans = append(c.spans, span.GetName())
+				}
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return c
+}
+
+// SpanNames returns names of received spans
+func (c *testCollector) SpanNames() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string{}, c.spans...)
+}
+
+// getFreeAddress returns local address with free TCP port
+func getFreeAddress() string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	defer l.Close()
+	return l.Addr().String()
+}
+
 func newOpts() *options.Options {
 	return &options.Options{
 		ConfigMapName:      testConfigMapName,
 		ConfigMapNamespace: testConfigMapNamespace,
 		ConfigMapKey:       testConfigMapKey,
+		MetricsBindAddress: "0",
+		APIRetryAttempts:   3,
+		APIRetryBackoff:    100 * time.Millisecond,
+		APIRetryMaxBackoff: time.Second,
 	}
 }
 
@@ -106,6 +223,11 @@ var _ = Describe("Init container", func() {
 				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
 				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
 			}, 30, 1).Should(Succeed())
+			// the annotation is owned by the init container
+			Expect(node.GetManagedFields()).To(ContainElement(SatisfyAll(
+				HaveField("Manager", "network-operator-init-container"),
+				HaveField("Operation", metav1.ManagedFieldsOperationApply),
+				HaveField("FieldsV1.Raw", ContainSubstring(`"f:`+testAnnotation+`"`)))))
 			// remove annotation
 			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
 				types.MergePatchType, []byte(
@@ -116,13 +238,59 @@ var _ = Describe("Init container", func() {
 		}()
 		Eventually(testDone, 1*time.Minute).Should(BeClosed())
 	})
-	It("Unknown node", func() {
+	It("Startup jitter and API client options", func() {
 		testDone := make(chan interface{})
 		go func() {
 			defer close(testDone)
 			defer GinkgoRecover()
+			// built-in types are sent as protobuf, the unstructured NodeMaintenance object falls back to JSON
 			opts := newOpts()
-			opts.NodeName = "unknown-node"
+			opts.NodeName = testNodeName
+			opts.StartupJitter = time.Second
+			opts.KubeAPIQPS = 5
+			opts.KubeAPIBurst = 10
+			opts.KubeAPIProtobuf = true
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:   true,
+				WaitMode: configPgk.WaitModeNodeMaintenance,
+				NodeMaintenance: configPgk.NodeMaintenanceConfig{
+					Spec: map[string]interface{}{"cordon": true},
+				},
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			nm := &unstructured.Unstructured{}
+			nm.SetGroupVersionKind(schema.GroupVersionKind{
+				Group: "maintenance.nvidia.com", Version: "v1alpha1", Kind: "NodeMaintenance"})
+			nmKey := types.NamespacedName{Namespace: "default", Name: "network-operator-init-container-" + testNodeName}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, nmKey, nm)).NotTo(HaveOccurred())
+			}, 30, 1).Should(Succeed())
+			Expect(nm.Object["spec"]).To(HaveKeyWithValue("cordon", true))
+			Expect(unstructured.SetNestedSlice(nm.Object, []interface{}{map[string]interface{}{
+				"type": "Ready", "status": "True", "reason": "Ready", "message": "",
+				"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+			}}, "status", "conditions")).NotTo(HaveOccurred())
+			Expect(k8sClient.Status().Update(testCtx, nm)).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apiErrors.IsNotFound(k8sClient.Get(testCtx, nmKey, nm))).To(BeTrue())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Health probe and status endpoints", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.HealthProbeBindAddress = getFreeAddress()
+			opts.StatusBindAddress = getFreeAddress()
 			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
 				Enable:     true,
 				Annotation: testAnnotation,
@@ -133,18 +301,44 @@ var _ = Describe("Init container", func() {
 				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
 				close(appExit)
 			}()
+			Eventually(func(g Gomega) {
+				resp, err := http.Get("http://" + opts.StatusBindAddress + "/status")
+				g.Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				data, err := io.ReadAll(resp.Body)
+				g.Expect(err).NotTo(HaveOccurred())
+				s := status.Status{}
+				g.Expect(json.Unmarshal(data, &s)).NotTo(HaveOccurred())
+				g.Expect(s.Phase).To(Equal("WaitForAnnotationRemoval"))
+				g.Expect(s.WaitingSince).NotTo(BeNil())
+			}, 30, 1).Should(Succeed())
+			Eventually(func(g Gomega) {
+				resp, err := http.Get("http://" + opts.HealthProbeBindAddress + "/readyz")
+				g.Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			}, 30, 1).Should(Succeed())
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
 			Eventually(appExit, 30, 1).Should(BeClosed())
-			Expect(err).To(HaveOccurred())
+			Expect(err).NotTo(HaveOccurred())
 		}()
 		Eventually(testDone, 1*time.Minute).Should(BeClosed())
 	})
-	It("Canceled", func() {
+	It("Tracing", func() {
 		testDone := make(chan interface{})
 		go func() {
 			defer close(testDone)
 			defer GinkgoRecover()
+			collector := newTestCollector()
+			defer collector.Close()
 			opts := newOpts()
 			opts.NodeName = testNodeName
+			opts.TracingEndpoint = collector.URL
 			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
 				Enable:     true,
 				Annotation: testAnnotation,
@@ -155,27 +349,1437 @@ var _ = Describe("Init container", func() {
 				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
 				close(appExit)
 			}()
-			testCFunc()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			payload, err := handshake.Decode(node.GetAnnotations()[testAnnotation])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(payload.TraceContext).To(HaveKey("traceparent"))
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
 			Eventually(appExit, 30, 1).Should(BeClosed())
-			Expect(err).To(HaveOccurred())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(collector.SpanNames()).To(ContainElements("RunNetworkOperatorInitContainer",
+				"LoadConfig", "Preflight", "SetAnnotation", "WaitForAnnotationRemoval", "Release"))
 		}()
 		Eventually(testDone, 1*time.Minute).Should(BeClosed())
 	})
-	It("Failed to read config", func() {
+	It("Additional gates", func() {
 		testDone := make(chan interface{})
 		go func() {
 			defer close(testDone)
 			defer GinkgoRecover()
 			opts := newOpts()
-			opts.NodeName = "unknown-node"
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:                true,
+				Annotation:            testAnnotation,
+				AdditionalAnnotations: []string{testAdditionalAnnotation},
+				AdditionalLabels:      []string{testLabel},
+			}})
 			var err error
 			appExit := make(chan interface{})
 			go func() {
 				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
 				close(appExit)
 			}()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()).To(HaveKey(testAnnotation))
+				g.Expect(node.GetAnnotations()).To(HaveKey(testAdditionalAnnotation))
+				g.Expect(node.GetLabels()).To(HaveKey(testLabel))
+			}, 30, 1).Should(Succeed())
+			// remove the annotation and the label, the additional annotation is still set
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null},"labels":{%q: null}}}`,
+						testAnnotation, testLabel))))).NotTo(HaveOccurred())
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAdditionalAnnotation))))).NotTo(HaveOccurred())
 			Eventually(appExit, 30, 1).Should(BeClosed())
-			Expect(err).To(HaveOccurred())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Label gate type", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:     true,
+				GateType:   configPgk.GateTypeLabel,
+				Label:      testLabel,
+				Annotation: testAnnotation,
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetLabels()).To(HaveKeyWithValue(testLabel, handshake.LabelValueWaiting))
+				g.Expect(node.GetAnnotations()).To(HaveKey(testAnnotation))
+			}, 30, 1).Should(Succeed())
+			nodes := &corev1.NodeList{}
+			Expect(k8sClient.List(testCtx, nodes,
+				client.MatchingLabels{testLabel: handshake.LabelValueWaiting})).NotTo(HaveOccurred())
+			Expect(nodes.Items).To(HaveLen(1))
+			// removal of the annotation doesn't release the container
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			// the label with an empty value releases the container the same way as an annotation with an empty value
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: "details"},"labels":{%q: ""}}}`,
+						testAnnotation, testLabel))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+			// the annotation with details is removed by the container
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"labels":{%q: null}}}`, testLabel))))).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Heartbeat", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:            true,
+				Annotation:        testAnnotation,
+				HeartbeatInterval: metav1.Duration{Duration: time.Second},
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			initial, err := handshake.Decode(node.GetAnnotations()[testAnnotation])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(initial.Heartbeat).NotTo(BeNil())
+			Expect(initial.HeartbeatTimeoutSeconds).To(Equal(int64(3)))
+			// heartbeat should be renewed
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				payload, err := handshake.Decode(node.GetAnnotations()[testAnnotation])
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(payload.Heartbeat).NotTo(BeNil())
+				g.Expect(payload.Heartbeat.After(initial.Heartbeat.Time)).To(BeTrue())
+				g.Expect(payload.HeartbeatExpired(time.Now())).To(BeFalse())
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+			// heartbeat should not restore removed annotation
+			Consistently(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+			}, 2, 1).Should(Succeed())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Node name from pod", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			pod := createPod("driver-self", testNodeName, nil, nil)
+			opts := newOpts()
+			opts.NodeNameFromPod = true
+			opts.PodName = pod.Name
+			opts.PodNamespace = pod.Namespace
+			opts.HostRoot = GinkgoT().TempDir()
+			opts.TerminationMessagePath = ""
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				},
+				Preflight: configPgk.PreflightConfig{
+					DiskSpace: []configPgk.DiskSpaceConfig{{Path: "/", MinFree: resource.MustParse("1Ki")}},
+				}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				payload, err := handshake.Decode(node.GetAnnotations()[testAnnotation])
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(payload.Owner).NotTo(BeNil())
+				g.Expect(payload.Owner.Name).To(Equal(pod.Name))
+				g.Expect(payload.Owner.UID).To(Equal(string(pod.UID)))
+			}, 30, 1).Should(Succeed())
+			Expect(opts.NodeName).To(Equal(testNodeName))
+			Expect(opts.IdentitySources).To(HaveKeyWithValue("pod-uid", options.SourcePod))
+			Expect(opts.IdentitySources).To(HaveKeyWithValue("node-name", options.SourcePod))
+			// preflight events are recorded for the Node and the pod
+			Eventually(func(g Gomega) {
+				events := &corev1.EventList{}
+				g.Expect(k8sClient.List(testCtx, events, client.InNamespace(pod.Namespace),
+					client.MatchingFields{"involvedObject.name": pod.Name})).NotTo(HaveOccurred())
+				g.Expect(events.Items).NotTo(BeEmpty())
+				g.Expect(events.Items[0].InvolvedObject.Kind).To(Equal("Pod"))
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Node name from pod - pod not scheduled", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			pod := createPod("driver-pending", "", nil, nil)
+			opts := newOpts()
+			opts.NodeNameFromPod = true
+			opts.PodName = pod.Name
+			opts.PodNamespace = pod.Namespace
+			opts.TerminationMessagePath = filepath.Join(GinkgoT().TempDir(), "termination-log")
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).To(MatchError(ContainSubstring("is not scheduled to a node")))
+			Expect(os.ReadFile(opts.TerminationMessagePath)).To(
+				ContainSubstring("pod " + pod.Namespace + "/" + pod.Name))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Stale annotation - node rebooted", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			setNodeBootID(testNodeName, "boot-2")
+			DeferCleanup(setNodeBootID, testNodeName, "")
+			setNodeAnnotation(testNodeName, &handshake.Payload{BootID: "boot-1",
+				Owner: &handshake.Owner{Namespace: testConfigMapNamespace, Name: "old-pod"}})
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.PodName = "new-pod"
+			opts.PodNamespace = testConfigMapNamespace
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:     true,
+				Annotation: testAnnotation,
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				payload, err := handshake.Decode(node.GetAnnotations()[testAnnotation])
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(payload.Owner).NotTo(BeNil())
+				g.Expect(payload.Owner.Name).To(Equal("new-pod"))
+				g.Expect(payload.BootID).To(Equal("boot-2"))
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Stale annotation - owner pod not found", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			setNodeAnnotation(testNodeName, &handshake.Payload{
+				Owner: &handshake.Owner{Namespace: testConfigMapNamespace, Name: "old-pod"}})
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.PodName = "new-pod"
+			opts.PodNamespace = testConfigMapNamespace
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:     true,
+				Annotation: testAnnotation,
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				payload, err := handshake.Decode(node.GetAnnotations()[testAnnotation])
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(payload.Owner).NotTo(BeNil())
+				g.Expect(payload.Owner.Name).To(Equal("new-pod"))
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Stale annotation - owner pod exists", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			ownerPod := createPod("old-pod", testNodeName, nil, nil)
+			setNodeAnnotation(testNodeName, &handshake.Payload{
+				Owner: &handshake.Owner{Namespace: testConfigMapNamespace, Name: ownerPod.Name,
+					UID: string(ownerPod.UID)}})
+			DeferCleanup(func() {
+				Expect(k8sClient.Patch(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNodeName}},
+					client.RawPatch(types.MergePatchType, []byte(
+						fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+							testAnnotation))))).NotTo(HaveOccurred())
+			})
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			value := node.GetAnnotations()[testAnnotation]
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.PodName = "new-pod"
+			opts.PodNamespace = testConfigMapNamespace
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:     true,
+				Annotation: testAnnotation,
+			}})
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).To(MatchError(ContainSubstring("OwnerPodExists")))
+			// the annotation of the running owner is kept
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()[testAnnotation]).To(Equal(value))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	DescribeTable("Stale annotation - owner pod terminated",
+		func(terminate func(pod *corev1.Pod)) {
+			testDone := make(chan interface{})
+			go func() {
+				defer close(testDone)
+				defer GinkgoRecover()
+				ownerPod := createPod("old-pod", testNodeName, nil, nil)
+				terminate(ownerPod)
+				setNodeAnnotation(testNodeName, &handshake.Payload{
+					Owner: &handshake.Owner{Namespace: testConfigMapNamespace, Name: ownerPod.Name,
+						UID: string(ownerPod.UID)}})
+				opts := newOpts()
+				opts.NodeName = testNodeName
+				opts.PodName = "new-pod"
+				opts.PodNamespace = testConfigMapNamespace
+				createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				}})
+				var err error
+				appExit := make(chan interface{})
+				go func() {
+					err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+					close(appExit)
+				}()
+				node := &corev1.Node{}
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+					payload, err := handshake.Decode(node.GetAnnotations()[testAnnotation])
+					g.Expect(err).NotTo(HaveOccurred())
+					g.Expect(payload.Owner).NotTo(BeNil())
+					g.Expect(payload.Owner.Name).To(Equal("new-pod"))
+				}, 30, 1).Should(Succeed())
+				Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+					types.MergePatchType, []byte(
+						fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+							testAnnotation))))).NotTo(HaveOccurred())
+				Eventually(appExit, 30, 1).Should(BeClosed())
+				Expect(err).NotTo(HaveOccurred())
+			}()
+			Eventually(testDone, 1*time.Minute).Should(BeClosed())
+		},
+		Entry("terminating", func(pod *corev1.Pod) {
+			// the finalizer keeps the deleted pod in the terminating state
+			pod.Finalizers = []string{"example.com/test"}
+			Expect(k8sClient.Update(ctx, pod)).NotTo(HaveOccurred())
+			DeferCleanup(func() {
+				Expect(k8sClient.Patch(ctx, pod, client.RawPatch(types.MergePatchType,
+					[]byte(`{"metadata":{"finalizers":null}}`)))).NotTo(HaveOccurred())
+			})
+			Expect(k8sClient.Delete(ctx, pod)).NotTo(HaveOccurred())
+		}),
+		Entry("completed", func(pod *corev1.Pod) {
+			pod.Status.Phase = corev1.PodSucceeded
+			Expect(k8sClient.Status().Update(ctx, pod)).NotTo(HaveOccurred())
+		}),
+	)
+	It("Condition wait mode - released", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:   true,
+				WaitMode: configPgk.WaitModeCondition,
+				Condition: configPgk.ConditionConfig{
+					Release: fmt.Sprintf(`has(node.metadata.labels) && node.metadata.labels[%q] == "release"`, testLabel),
+					Failure: fmt.Sprintf(`has(node.metadata.labels) && node.metadata.labels[%q] == "fail"`, testLabel),
+				},
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+			DeferCleanup(func() {
+				Expect(k8sClient.Patch(ctx, node, client.RawPatch(types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"labels":{%q: null}}}`, testLabel))))).NotTo(HaveOccurred())
+			})
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(types.MergePatchType, []byte(
+				fmt.Sprintf(`{"metadata":{"labels":{%q: "release"}}}`, testLabel))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Condition wait mode - failed", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:   true,
+				WaitMode: configPgk.WaitModeCondition,
+				Condition: configPgk.ConditionConfig{
+					Release: fmt.Sprintf(`has(node.metadata.labels) && node.metadata.labels[%q] == "release"`, testLabel),
+					Failure: fmt.Sprintf(`has(node.metadata.labels) && node.metadata.labels[%q] == "fail"`, testLabel),
+				},
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+			DeferCleanup(func() {
+				Expect(k8sClient.Patch(ctx, node, client.RawPatch(types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"labels":{%q: null}}}`, testLabel))))).NotTo(HaveOccurred())
+			})
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(types.MergePatchType, []byte(
+				fmt.Sprintf(`{"metadata":{"labels":{%q: "fail"}}}`, testLabel))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).To(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("NodeMaintenance wait mode", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:   true,
+				WaitMode: configPgk.WaitModeNodeMaintenance,
+				NodeMaintenance: configPgk.NodeMaintenanceConfig{
+					Spec: map[string]interface{}{"cordon": true},
+				},
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			nm := &unstructured.Unstructured{}
+			nm.SetGroupVersionKind(schema.GroupVersionKind{
+				Group: "maintenance.nvidia.com", Version: "v1alpha1", Kind: "NodeMaintenance"})
+			nmKey := types.NamespacedName{Namespace: "default", Name: "network-operator-init-container-" + testNodeName}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, nmKey, nm)).NotTo(HaveOccurred())
+			}, 30, 1).Should(Succeed())
+			Expect(nm.Object["spec"]).To(Equal(map[string]interface{}{
+				"nodeName":    testNodeName,
+				"requestorID": "network-operator-init-container",
+				"cordon":      true,
+			}))
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			Expect(unstructured.SetNestedSlice(nm.Object, []interface{}{map[string]interface{}{
+				"type": "Ready", "status": "True", "reason": "Ready", "message": "",
+				"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+			}}, "status", "conditions")).NotTo(HaveOccurred())
+			Expect(k8sClient.Status().Update(testCtx, nm)).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+			// the object is deleted on exit
+			Expect(apiErrors.IsNotFound(k8sClient.Get(testCtx, nmKey, nm))).To(BeTrue())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("NodeMaintenance wait mode - existing object", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:   true,
+				WaitMode: configPgk.WaitModeNodeMaintenance,
+				NodeMaintenance: configPgk.NodeMaintenanceConfig{
+					Spec: map[string]interface{}{"cordon": true},
+				},
+			}})
+			gvk := schema.GroupVersionKind{Group: "maintenance.nvidia.com", Version: "v1alpha1", Kind: "NodeMaintenance"}
+			nmKey := types.NamespacedName{Namespace: "default", Name: "network-operator-init-container-" + testNodeName}
+			for _, existingSpec := range []map[string]interface{}{
+				// the spec is updated
+				{"nodeName": testNodeName, "requestorID": "network-operator-init-container", "cordon": false},
+				// the object is recreated
+				{"nodeName": testNodeName, "requestorID": "other-requestor", "cordon": true},
+			} {
+				existing := &unstructured.Unstructured{Object: map[string]interface{}{"spec": existingSpec}}
+				existing.SetGroupVersionKind(gvk)
+				existing.SetNamespace(nmKey.Namespace)
+				existing.SetName(nmKey.Name)
+				Expect(k8sClient.Create(testCtx, existing)).NotTo(HaveOccurred())
+
+				var err error
+				appExit := make(chan interface{})
+				go func() {
+					err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+					close(appExit)
+				}()
+				nm := &unstructured.Unstructured{}
+				nm.SetGroupVersionKind(gvk)
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(testCtx, nmKey, nm)).NotTo(HaveOccurred())
+					g.Expect(nm.Object["spec"]).To(Equal(map[string]interface{}{
+						"nodeName":    testNodeName,
+						"requestorID": "network-operator-init-container",
+						"cordon":      true,
+					}))
+				}, 30, 1).Should(Succeed())
+				if existingSpec["requestorID"] == "other-requestor" {
+					Expect(nm.GetUID()).NotTo(Equal(existing.GetUID()))
+				} else {
+					Expect(nm.GetUID()).To(Equal(existing.GetUID()))
+				}
+				Expect(unstructured.SetNestedSlice(nm.Object, []interface{}{map[string]interface{}{
+					"type": "Ready", "status": "True", "reason": "Ready", "message": "",
+					"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+				}}, "status", "conditions")).NotTo(HaveOccurred())
+				Expect(k8sClient.Status().Update(testCtx, nm)).NotTo(HaveOccurred())
+				Eventually(appExit, 30, 1).Should(BeClosed())
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}()
+		Eventually(testDone, 2*time.Minute).Should(BeClosed())
+	})
+	It("Drain", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				},
+				Drain: configPgk.DrainConfig{
+					Enable:       true,
+					PodResources: []string{"rdma/*"},
+				},
+			})
+			rdmaPod := createPod("rdma-pod", testNodeName, []corev1.ResourceName{"rdma/hca_shared_devices_a"},
+				&metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs", UID: "rs-uid",
+					Controller: ptr.To(true)})
+			otherPod := createPod("other-pod", testNodeName, nil, nil)
+			dsPod := createPod("ds-pod", testNodeName, []corev1.ResourceName{"rdma/hca_shared_devices_a"},
+				&metav1.OwnerReference{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "ds", UID: "ds-uid",
+					Controller: ptr.To(true)})
+			pdb := &policyv1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{Name: "rdma-pod", Namespace: testConfigMapNamespace},
+				Spec: policyv1.PodDisruptionBudgetSpec{
+					MinAvailable: ptr.To(intstr.FromInt32(1)),
+					Selector:     &metav1.LabelSelector{MatchLabels: rdmaPod.Labels},
+				},
+			}
+			Expect(k8sClient.Create(testCtx, pdb)).NotTo(HaveOccurred())
+
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.Spec.Unschedulable).To(BeTrue())
+			}, 30, 1).Should(Succeed())
+			// eviction is blocked by PodDisruptionBudget
+			Consistently(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, client.ObjectKeyFromObject(rdmaPod), rdmaPod)).NotTo(HaveOccurred())
+				g.Expect(rdmaPod.DeletionTimestamp).To(BeNil())
+			}, 3, 1).Should(Succeed())
+			Expect(k8sClient.Delete(testCtx, pdb)).NotTo(HaveOccurred())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, client.ObjectKeyFromObject(rdmaPod), rdmaPod)).NotTo(HaveOccurred())
+				g.Expect(rdmaPod.DeletionTimestamp).NotTo(BeNil())
+			}, 30, 1).Should(Succeed())
+			Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+			// there is no kubelet in the test environment, complete the deletion of the pod
+			Expect(k8sClient.Delete(testCtx, rdmaPod, client.GracePeriodSeconds(0))).NotTo(HaveOccurred())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			for _, pod := range []*corev1.Pod{otherPod, dsPod} {
+				Expect(k8sClient.Get(testCtx, client.ObjectKeyFromObject(pod), pod)).NotTo(HaveOccurred())
+				Expect(pod.DeletionTimestamp).To(BeNil())
+			}
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.Spec.Unschedulable).To(BeFalse())
+		}()
+		Eventually(testDone, 2*time.Minute).Should(BeClosed())
+	})
+	It("Drain - restarted after cordon", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				},
+				Drain: configPgk.DrainConfig{
+					Enable:       true,
+					PodResources: []string{"rdma/*"},
+				},
+			})
+			// the Node was cordoned by the previous run of the container
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(types.MergePatchType, []byte(
+				`{"metadata":{"annotations":{"network-operator-init-container/cordoned":"true"}},`+
+					`"spec":{"unschedulable":true}}`)))).NotTo(HaveOccurred())
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.Spec.Unschedulable).To(BeFalse())
+			Expect(node.GetAnnotations()).NotTo(HaveKey("network-operator-init-container/cordoned"))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Drain - pods without controller", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				},
+				Drain: configPgk.DrainConfig{Enable: true},
+			})
+			barePod := createPod("bare-pod", testNodeName, nil, nil)
+			DeferCleanup(func() {
+				Expect(k8sClient.Patch(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNodeName}},
+					client.RawPatch(types.MergePatchType, []byte(
+						`{"metadata":{"annotations":{"network-operator-init-container/cordoned":null}},`+
+							`"spec":{"unschedulable":false}}`)))).NotTo(HaveOccurred())
+			})
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).To(MatchError(ContainSubstring("bare-pod")))
+			Expect(k8sClient.Get(testCtx, client.ObjectKeyFromObject(barePod), barePod)).NotTo(HaveOccurred())
+			Expect(barePod.DeletionTimestamp).To(BeNil())
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Wait for workloads", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				},
+				WaitForWorkloads: configPgk.WaitForWorkloadsConfig{
+					Enable:          true,
+					Resources:       []string{"rdma/*"},
+					HostRDMADevices: true,
+				},
+			})
+			rdmaPod := createPod("rdma-pod", testNodeName, []corev1.ResourceName{"rdma/hca_shared_devices_a"}, nil)
+			createPod("other-pod", testNodeName, nil, nil)
+			hostDevPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "host-dev-pod", Namespace: testConfigMapNamespace},
+				Spec: corev1.PodSpec{NodeName: testNodeName,
+					Containers: []corev1.Container{{Name: "test", Image: "test"}},
+					Volumes: []corev1.Volume{{Name: "dev", VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{Path: "/dev/infiniband"}}}}},
+			}
+			Expect(k8sClient.Create(testCtx, hostDevPod)).NotTo(HaveOccurred())
+			hostDevPod.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(testCtx, hostDevPod)).NotTo(HaveOccurred())
+			// the pending pod doesn't use RDMA yet and doesn't block the container
+			pendingPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "pending-rdma-pod", Namespace: testConfigMapNamespace},
+				Spec: corev1.PodSpec{NodeName: testNodeName, Containers: []corev1.Container{{
+					Name: "test", Image: "test", Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+						"rdma/hca_shared_devices_a": resource.MustParse("1")}}}}},
+			}
+			Expect(k8sClient.Create(testCtx, pendingPod)).NotTo(HaveOccurred())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, pendingPod, client.GracePeriodSeconds(0))).NotTo(HaveOccurred())
+			})
+
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			Expect(k8sClient.Delete(testCtx, rdmaPod, client.GracePeriodSeconds(0))).NotTo(HaveOccurred())
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			Expect(k8sClient.Delete(testCtx, hostDevPod, client.GracePeriodSeconds(0))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 2*time.Minute).Should(BeClosed())
+	})
+	It("Wait for previous driver pod", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.PodName = "driver-new"
+			opts.PodNamespace = testConfigMapNamespace
+			createConfig(configPgk.Config{
+				WaitForPreviousPod: configPgk.WaitForPreviousPodConfig{Enable: true},
+			})
+			owner := &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "driver", UID: "driver-uid",
+				Controller: ptr.To(true)}
+			createPod("driver-new", testNodeName, nil, owner)
+			oldPod := createPod("driver-old", testNodeName, nil, owner)
+			createPod("other-ds-pod", testNodeName, nil, &metav1.OwnerReference{APIVersion: "apps/v1",
+				Kind: "DaemonSet", Name: "other", UID: "other-uid", Controller: ptr.To(true)})
+
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			Expect(k8sClient.Delete(testCtx, oldPod, client.GracePeriodSeconds(0))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Wait for previous driver pod - no pod name", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{
+				WaitForPreviousPod: configPgk.WaitForPreviousPodConfig{Enable: true},
+			})
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).To(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Node lock - wait for another instance", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{Enable: true, Annotation: testAnnotation},
+				NodeLock: configPgk.NodeLockConfig{Enable: true,
+					LeaseDuration: metav1.Duration{Duration: 3 * time.Second}},
+			})
+			lease := &coordinationv1.Lease{}
+			leaseKey := types.NamespacedName{Name: "network-operator-init-container-" + testNodeName,
+				Namespace: testConfigMapNamespace}
+			removeAnnotation := func() {
+				node := &corev1.Node{}
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+					g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+				}, 30, 1).Should(Succeed())
+				Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+					types.MergePatchType, []byte(
+						fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+							testAnnotation))))).NotTo(HaveOccurred())
+			}
+			run := func(podName string) (chan interface{}, *error) {
+				opts := newOpts()
+				opts.NodeName = testNodeName
+				opts.PodName = podName
+				opts.PodNamespace = testConfigMapNamespace
+				var err error
+				appExit := make(chan interface{})
+				go func() {
+					err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+					close(appExit)
+				}()
+				return appExit, &err
+			}
+			firstExit, firstErr := run("driver-first")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, leaseKey, lease)).NotTo(HaveOccurred())
+				g.Expect(lease.Spec.HolderIdentity).To(HaveValue(Equal(testConfigMapNamespace + "/driver-first")))
+			}, 30, 1).Should(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, lease)).NotTo(HaveOccurred()) })
+
+			secondExit, secondErr := run("driver-second")
+			Consistently(secondExit, 3, 1).ShouldNot(BeClosed())
+			Expect(k8sClient.Get(testCtx, leaseKey, lease)).NotTo(HaveOccurred())
+			Expect(lease.Spec.HolderIdentity).To(HaveValue(Equal(testConfigMapNamespace + "/driver-first")))
+
+			removeAnnotation()
+			Eventually(firstExit, 30, 1).Should(BeClosed())
+			Expect(*firstErr).NotTo(HaveOccurred())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, leaseKey, lease)).NotTo(HaveOccurred())
+				g.Expect(lease.Spec.HolderIdentity).To(HaveValue(Equal(testConfigMapNamespace + "/driver-second")))
+			}, 30, 1).Should(Succeed())
+
+			removeAnnotation()
+			Eventually(secondExit, 30, 1).Should(BeClosed())
+			Expect(*secondErr).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 2*time.Minute).Should(BeClosed())
+	})
+	It("Node lock - held by another instance", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{Enable: true, Annotation: testAnnotation},
+				NodeLock:       configPgk.NodeLockConfig{Enable: true, Policy: configPgk.NodeLockPolicyFail},
+			})
+			lease := &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{Name: "network-operator-init-container-" + testNodeName,
+					Namespace: testConfigMapNamespace},
+				Spec: coordinationv1.LeaseSpec{
+					HolderIdentity:       ptr.To("other-instance"),
+					LeaseDurationSeconds: ptr.To[int32](600),
+					AcquireTime:          &metav1.MicroTime{Time: time.Now()},
+					RenewTime:            &metav1.MicroTime{Time: time.Now()},
+				},
+			}
+			Expect(k8sClient.Create(testCtx, lease)).NotTo(HaveOccurred())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, lease)).NotTo(HaveOccurred()) })
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).To(MatchError(ContainSubstring("held by other-instance")))
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Node lock - lost", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.PodName = "driver"
+			opts.PodNamespace = testConfigMapNamespace
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{Enable: true, Annotation: testAnnotation},
+				NodeLock: configPgk.NodeLockConfig{Enable: true,
+					LeaseDuration: metav1.Duration{Duration: 3 * time.Second}},
+			})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			lease := &coordinationv1.Lease{}
+			leaseKey := types.NamespacedName{Name: "network-operator-init-container-" + testNodeName,
+				Namespace: testConfigMapNamespace}
+			node := &corev1.Node{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Get(testCtx, leaseKey, lease)).NotTo(HaveOccurred())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, lease)).NotTo(HaveOccurred()) })
+			// another instance takes over the lock
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, leaseKey, lease)).NotTo(HaveOccurred())
+				lease.Spec.HolderIdentity = ptr.To("other-instance")
+				lease.Spec.LeaseDurationSeconds = ptr.To[int32](600)
+				lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+				g.Expect(k8sClient.Update(testCtx, lease)).NotTo(HaveOccurred())
+			}, 30, 1).Should(Succeed())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).To(MatchError(ContainSubstring("node lock lost")))
+			// the container doesn't change the node without the lock
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()).To(HaveKey(testAnnotation))
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(types.MergePatchType, []byte(
+				fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`, testAnnotation))))).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Wait for node", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = "new-node"
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{Enable: true, Annotation: testAnnotation},
+				WaitForNode:    configPgk.WaitForNodeConfig{Enable: true},
+			})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			node := createNode("new-node")
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, node)).NotTo(HaveOccurred()) })
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: "new-node"}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Wait for node - timeout", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = "unknown-node"
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{Enable: true, Annotation: testAnnotation},
+				WaitForNode: configPgk.WaitForNodeConfig{Enable: true,
+					Timeout: metav1.Duration{Duration: 2 * time.Second}},
+			})
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).To(MatchError(ContainSubstring("was not created within 2s")))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Node deleted while waiting", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = "deleted-node"
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{Enable: true, Annotation: testAnnotation},
+			})
+			node := createNode("deleted-node")
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: "deleted-node"}, node)).NotTo(HaveOccurred())
+				g.Expect(node.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			Expect(k8sClient.Delete(testCtx, node)).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).To(MatchError(ContainSubstring("was deleted while waiting for the gate")))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("ConfigMap handshake object", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			// the user can only read Nodes and write ConfigMaps in the namespace
+			user, err := testEnv.AddUser(envtest.User{Name: "namespaced-user"}, cfg)
+			Expect(err).NotTo(HaveOccurred())
+			clusterRole := &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "namespaced-user"},
+				Rules: []rbacv1.PolicyRule{
+					{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}},
+				},
+			}
+			Expect(k8sClient.Create(testCtx, clusterRole)).NotTo(HaveOccurred())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, clusterRole)).NotTo(HaveOccurred()) })
+			clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "namespaced-user"},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRole.Name},
+				Subjects: []rbacv1.Subject{
+					{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "namespaced-user"}},
+			}
+			Expect(k8sClient.Create(testCtx, clusterRoleBinding)).NotTo(HaveOccurred())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, clusterRoleBinding)).NotTo(HaveOccurred()) })
+			role := &rbacv1.Role{
+				ObjectMeta: metav1.ObjectMeta{Name: "namespaced-user", Namespace: testConfigMapNamespace},
+				Rules: []rbacv1.PolicyRule{
+					{APIGroups: []string{""}, Resources: []string{"configmaps"},
+						Verbs: []string{"get", "list", "watch", "create", "patch"}},
+				},
+			}
+			Expect(k8sClient.Create(testCtx, role)).NotTo(HaveOccurred())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, role)).NotTo(HaveOccurred()) })
+			roleBinding := &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "namespaced-user", Namespace: testConfigMapNamespace},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name},
+				Subjects: []rbacv1.Subject{
+					{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "namespaced-user"}},
+			}
+			Expect(k8sClient.Create(testCtx, roleBinding)).NotTo(HaveOccurred())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, roleBinding)).NotTo(HaveOccurred()) })
+
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.HealthProbeBindAddress = getFreeAddress()
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:            true,
+				Annotation:        testAnnotation,
+				HandshakeObject:   configPgk.HandshakeObjectConfigMap,
+				HeartbeatInterval: metav1.Duration{Duration: time.Second},
+			}})
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, user.Config(), opts)
+				close(appExit)
+			}()
+			cm := &corev1.ConfigMap{}
+			cmKey := types.NamespacedName{Name: "network-operator-init-container-" + testNodeName,
+				Namespace: testConfigMapNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, cmKey, cm)).NotTo(HaveOccurred())
+				g.Expect(cm.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, cm)).NotTo(HaveOccurred()) })
+			Expect(cm.GetLabels()).To(HaveKeyWithValue(corev1.LabelHostname, testNodeName))
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+			// the container is ready without the Node watch
+			Eventually(func(g Gomega) {
+				resp, err := http.Get("http://" + opts.HealthProbeBindAddress + "/readyz")
+				g.Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			}, 30, 1).Should(Succeed())
+
+			Consistently(appExit, 3, 1).ShouldNot(BeClosed())
+			Expect(k8sClient.Patch(testCtx, cm, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testAnnotation))))).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("ConfigMap handshake object - deleted", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:          true,
+				Annotation:      testAnnotation,
+				HandshakeObject: configPgk.HandshakeObjectConfigMap,
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			cm := &corev1.ConfigMap{}
+			cmKey := types.NamespacedName{Name: "network-operator-init-container-" + testNodeName,
+				Namespace: testConfigMapNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(testCtx, cmKey, cm)).NotTo(HaveOccurred())
+				g.Expect(cm.GetAnnotations()[testAnnotation]).NotTo(BeEmpty())
+			}, 30, 1).Should(Succeed())
+			Consistently(appExit, 2, 1).ShouldNot(BeClosed())
+			Expect(k8sClient.Delete(testCtx, cm)).NotTo(HaveOccurred())
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).To(MatchError(ContainSubstring("handshake ConfigMap default/" + cmKey.Name +
+				" was deleted while waiting for the gate")))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Missing permissions", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			user, err := testEnv.AddUser(envtest.User{Name: "limited-user"}, cfg)
+			Expect(err).NotTo(HaveOccurred())
+			role := &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "limited-user"},
+				Rules: []rbacv1.PolicyRule{
+					{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+					{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "list", "watch"}},
+				},
+			}
+			Expect(k8sClient.Create(testCtx, role)).NotTo(HaveOccurred())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, role)).NotTo(HaveOccurred()) })
+			binding := &rbacv1.ClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "limited-user"},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role.Name},
+				Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "limited-user"}},
+			}
+			Expect(k8sClient.Create(testCtx, binding)).NotTo(HaveOccurred())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, binding)).NotTo(HaveOccurred()) })
+
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{Enable: true, Annotation: testAnnotation},
+				NodeLock:       configPgk.NodeLockConfig{Enable: true},
+			})
+			Eventually(func(g Gomega) {
+				err = app.RunNetworkOperatorInitContainer(testCtx, user.Config(), opts)
+				g.Expect(err).To(MatchError(ContainSubstring("missing permissions: " +
+					"get leases.coordination.k8s.io in namespace default (nodeLock), " +
+					"create leases.coordination.k8s.io in namespace default (nodeLock), " +
+					"update leases.coordination.k8s.io in namespace default (nodeLock), " +
+					"patch nodes (safeDriverLoad)")))
+			}, 30, 1).Should(Succeed())
+			Expect(err.Error()).To(ContainSubstring(`  - apiGroups: [""]
+    resources: ["nodes"]
+    verbs: ["patch"]
+  - apiGroups: ["coordination.k8s.io"]
+    resources: ["leases"]
+    verbs: ["get", "create", "update"]
+`))
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()).NotTo(HaveKey(testAnnotation))
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Unknown node", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = "unknown-node"
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:     true,
+				Annotation: testAnnotation,
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).To(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Canceled", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			createConfig(configPgk.Config{SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+				Enable:     true,
+				Annotation: testAnnotation,
+			}})
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			testCFunc()
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).To(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Failed to read config", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = "unknown-node"
+			var err error
+			appExit := make(chan interface{})
+			go func() {
+				err = app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				close(appExit)
+			}()
+			Eventually(appExit, 30, 1).Should(BeClosed())
+			Expect(err).To(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("Preflight failed - unsigned driver", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.HostRoot = GinkgoT().TempDir()
+			lockdownPath := filepath.Join(opts.HostRoot, preflight.LockdownPath)
+			Expect(os.MkdirAll(filepath.Dir(lockdownPath), 0o755)).NotTo(HaveOccurred())
+			Expect(os.WriteFile(lockdownPath, []byte("none [integrity] confidentiality"), 0o644)).NotTo(HaveOccurred())
+			opts.TerminationMessagePath = filepath.Join(opts.HostRoot, "termination-log")
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				},
+				Preflight: configPgk.PreflightConfig{
+					SecureBoot: configPgk.SecureBootConfig{Enable: true},
+				}})
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).To(HaveOccurred())
+			Expect(os.ReadFile(opts.TerminationMessagePath)).To(
+				ContainSubstring(preflight.ReasonUnsignedDriverRejected))
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()[testAnnotation]).To(BeEmpty())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	DescribeTable("Preflight - firmware policy",
+		func(policy configPgk.FirmwarePolicy, fail bool) {
+			testDone := make(chan interface{})
+			go func() {
+				defer close(testDone)
+				defer GinkgoRecover()
+				opts := newOpts()
+				opts.NodeName = testNodeName
+				opts.HostRoot = GinkgoT().TempDir()
+				opts.TerminationMessagePath = ""
+				// the driver is not bound, firmware version of the device is unknown
+				devPath := filepath.Join(opts.HostRoot, inventory.PCIDevicesPath, "0000:08:00.0")
+				Expect(os.MkdirAll(devPath, 0o755)).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(devPath, "vendor"), []byte("0x15b3\n"), 0o644)).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(devPath, "device"), []byte("0x101d\n"), 0o644)).NotTo(HaveOccurred())
+				createConfig(configPgk.Config{
+					Preflight: configPgk.PreflightConfig{
+						Firmware: configPgk.FirmwareConfig{
+							MinVersions: map[string]string{"0x101D": "22.36.1010"},
+							Policy:      policy,
+						},
+						ResultAnnotation: testPreflightAnnotation,
+					}})
+				err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+				if fail {
+					Expect(err).To(MatchError(ContainSubstring(preflight.ReasonFirmwareUnknown)))
+				} else {
+					Expect(err).NotTo(HaveOccurred())
+				}
+				node := &corev1.Node{}
+				Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+				results := []preflight.Result{}
+				Expect(json.Unmarshal([]byte(node.GetAnnotations()[testPreflightAnnotation]), &results)).
+					NotTo(HaveOccurred())
+				Expect(results).To(HaveLen(1))
+				Expect(results[0].Passed).To(BeFalse())
+				Expect(results[0].Reason).To(Equal(preflight.ReasonFirmwareUnknown))
+				Expect(results[0].Warning).To(Equal(!fail))
+			}()
+			Eventually(testDone, 1*time.Minute).Should(BeClosed())
+		},
+		Entry("fail", configPgk.FirmwarePolicyFail, true),
+		Entry("warn", configPgk.FirmwarePolicyWarn, false),
+	)
+	It("Preflight failed - results reported", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.HostRoot = GinkgoT().TempDir()
+			opts.TerminationMessagePath = ""
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				},
+				Preflight: configPgk.PreflightConfig{
+					KernelHeaders:    configPgk.KernelHeadersConfig{Enable: true},
+					DiskSpace:        []configPgk.DiskSpaceConfig{{Path: "/", MinFree: resource.MustParse("1Ki")}},
+					ResultAnnotation: testPreflightAnnotation,
+				}})
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).To(HaveOccurred())
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()[testAnnotation]).To(BeEmpty())
+			results := []preflight.Result{}
+			Expect(json.Unmarshal([]byte(node.GetAnnotations()[testPreflightAnnotation]), &results)).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].Passed).To(BeFalse())
+			Expect(results[0].Reason).To(Equal(preflight.ReasonKernelHeadersMissing))
+			Expect(results[1].Passed).To(BeTrue())
+			Expect(k8sClient.Patch(testCtx, node, client.RawPatch(
+				types.MergePatchType, []byte(
+					fmt.Sprintf(`{"metadata":{"annotations":{%q: null}}}`,
+						testPreflightAnnotation))))).NotTo(HaveOccurred())
+		}()
+		Eventually(testDone, 1*time.Minute).Should(BeClosed())
+	})
+	It("No network devices", func() {
+		testDone := make(chan interface{})
+		go func() {
+			defer close(testDone)
+			defer GinkgoRecover()
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.HostRoot = GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(opts.HostRoot, inventory.PCIDevicesPath), 0o755)).NotTo(HaveOccurred())
+			createConfig(configPgk.Config{
+				SafeDriverLoad: configPgk.SafeDriverLoadConfig{
+					Enable:     true,
+					Annotation: testAnnotation,
+				},
+				DeviceInventory: configPgk.DeviceInventoryConfig{Enable: true},
+			})
+			err := app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)
+			Expect(err).NotTo(HaveOccurred())
+			node := &corev1.Node{}
+			Expect(k8sClient.Get(testCtx, types.NamespacedName{Name: testNodeName}, node)).NotTo(HaveOccurred())
+			Expect(node.GetAnnotations()[testAnnotation]).To(BeEmpty())
 		}()
 		Eventually(testDone, 1*time.Minute).Should(BeClosed())
 	})
@@ -0,0 +1,171 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+const drainPollInterval = 5 * time.Second
+
+// runDrain cordons the Node and evicts pods from it, returns true if the Node was cordoned by the container
+func runDrain(ctx context.Context, logger logr.Logger, k8sClient client.Client,
+	opts *options.Options, cfg configPgk.DrainConfig) (bool, error) {
+	logger = logger.WithValues("node", opts.NodeName)
+	node := &corev1.Node{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: opts.NodeName}, node); err != nil {
+		logger.Error(err, "failed to read node object from the API")
+		return false, err
+	}
+	cordoned := false
+	switch {
+	case !node.Spec.Unschedulable:
+		if err := setNodeUnschedulable(ctx, k8sClient, opts.NodeName, true); err != nil {
+			logger.Error(err, "failed to cordon the node")
+			return false, err
+		}
+		logger.Info("node cordoned")
+		cordoned = true
+	case node.GetAnnotations()[cordonAnnotation] != "":
+		// the container was restarted after the Node was cordoned
+		logger.Info("node was cordoned by the container before")
+		cordoned = true
+	default:
+		logger.Info("node is already cordoned, it will not be uncordoned")
+	}
+
+	drainCtx, cFunc := context.WithTimeout(ctx, cfg.GetTimeout())
+	defer cFunc()
+	err := wait.PollUntilContextCancel(drainCtx, drainPollInterval, true, func(ctx context.Context) (bool, error) {
+		pods := &corev1.PodList{}
+		if err := k8sClient.List(ctx, pods, client.MatchingFields{"spec.nodeName": opts.NodeName}); err != nil {
+			logger.Error(err, "failed to list pods on the node")
+			return false, nil
+		}
+		evict := []*corev1.Pod{}
+		unmanaged := []string{}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if !shouldEvictPod(pod, cfg.PodResources) {
+				continue
+			}
+			if metav1.GetControllerOf(pod) == nil && !cfg.Force && pod.DeletionTimestamp == nil {
+				unmanaged = append(unmanaged, client.ObjectKeyFromObject(pod).String())
+			}
+			evict = append(evict, pod)
+		}
+		if len(unmanaged) != 0 {
+			// the pods are not recreated after the eviction, same as kubectl drain without --force
+			return false, fmt.Errorf("pods which are not managed by a controller can't be evicted "+
+				"without drain.force: %v", unmanaged)
+		}
+		pending := []string{}
+		for _, pod := range evict {
+			pending = append(pending, client.ObjectKeyFromObject(pod).String())
+			if pod.DeletionTimestamp == nil {
+				evictPod(ctx, logger, k8sClient, pod)
+			}
+		}
+		if len(pending) == 0 {
+			return true, nil
+		}
+		logger.Info("wait for pods to be evicted", "pods", pending)
+		return false, nil
+	})
+	if err != nil {
+		if wait.Interrupted(err) && ctx.Err() == nil {
+			err = fmt.Errorf("failed to drain the node in %s", cfg.GetTimeout())
+		}
+		logger.Error(err, "drain failed")
+		return cordoned, err
+	}
+	logger.Info("node drained")
+	return cordoned, nil
+}
+
+// evictPod evicts the pod with the Eviction API, PodDisruptionBudgets are honored by the API server,
+// errors are logged, the eviction is retried on the next poll
+func evictPod(ctx context.Context, logger logr.Logger, k8sClient client.Client, pod *corev1.Pod) {
+	logger = logger.WithValues("pod", client.ObjectKeyFromObject(pod).String())
+	err := k8sClient.SubResource("eviction").Create(ctx, pod, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}})
+	switch {
+	case err == nil:
+		logger.Info("pod evicted")
+	case apiErrors.IsNotFound(err):
+	case apiErrors.IsTooManyRequests(err):
+		logger.Info("pod eviction is blocked by PodDisruptionBudget", "reason", err.Error())
+	default:
+		logger.Error(err, "failed to evict pod")
+	}
+}
+
+// shouldEvictPod returns true if the pod should be evicted from the Node,
+// mirror pods, pods which belong to DaemonSets and completed pods are not evicted
+func shouldEvictPod(pod *corev1.Pod, resourcePatterns []string) bool {
+	if _, isMirror := pod.Annotations[corev1.MirrorPodAnnotationKey]; isMirror {
+		return false
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return false
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if len(resourcePatterns) == 0 {
+		return true
+	}
+	return podRequestsResources(pod, resourcePatterns)
+}
+
+// podRequestsResources returns true if any container of the pod requests or limits
+// a resource which matches one of the patterns
+func podRequestsResources(pod *corev1.Pod, resourcePatterns []string) bool {
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		for _, resources := range []corev1.ResourceList{c.Resources.Requests, c.Resources.Limits} {
+			for name := range resources {
+				if matchResource(string(name), resourcePatterns) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// matchResource returns true if the resource name matches one of the patterns
+func matchResource(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if matched, _ := path.Match(p, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/sriov"
+)
+
+// exported for unit tests of the startup
+var (
+	KubeAPIConfig = kubeAPIConfig
+	StartupDelay  = startupDelay
+)
+
+// SetVFConfigurator replaces configurator of the VFs, returns function which restores the original one
+func SetVFConfigurator(c sriov.VFConfigurator) func() {
+	orig := vfConfigurator
+	vfConfigurator = c
+	return func() { vfConfigurator = orig }
+}
+
+// RequiredPermissions returns descriptions of the permissions which are required by the configuration
+func RequiredPermissions(k8sClient client.Client, opts *options.Options, cfg *configPgk.Config) []string {
+	perms := requiredPermissions(k8sClient, opts, cfg)
+	descriptions := make([]string, 0, len(perms))
+	for _, p := range perms {
+		descriptions = append(descriptions, p.String())
+	}
+	return descriptions
+}
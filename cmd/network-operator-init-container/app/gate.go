@@ -0,0 +1,79 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/handshake"
+)
+
+// Gate is an annotation or a label on the handshake object, the Node object by default,
+// which blocks the driver loading until it is removed
+type Gate struct {
+	// the gate is a label if true, an annotation otherwise
+	Label bool
+	Name  string
+}
+
+// String returns string representation of the gate
+func (g Gate) String() string {
+	if g.Label {
+		return "label " + g.Name
+	}
+	return "annotation " + g.Name
+}
+
+// IsSet returns true if the gate is set on the object, the Node object or the handshake ConfigMap,
+// labels and annotations are set only if they have a non-empty value, an empty value releases the gate
+func (g Gate) IsSet(obj metav1.Object) bool {
+	if g.Label {
+		return obj.GetLabels()[g.Name] != ""
+	}
+	return obj.GetAnnotations()[g.Name] != ""
+}
+
+// safeLoadLabel returns the label which is used as the gate, returns empty string for the annotation gate type
+func safeLoadLabel(cfg configPgk.SafeDriverLoadConfig) string {
+	if cfg.GetGateType() == configPgk.GateTypeLabel {
+		return cfg.Label
+	}
+	return ""
+}
+
+// additionalGates returns additional gates from the configuration
+func additionalGates(cfg configPgk.SafeDriverLoadConfig) []Gate {
+	gates := make([]Gate, 0, len(cfg.AdditionalAnnotations)+len(cfg.AdditionalLabels))
+	for _, a := range cfg.AdditionalAnnotations {
+		gates = append(gates, Gate{Name: a})
+	}
+	for _, l := range cfg.AdditionalLabels {
+		gates = append(gates, Gate{Label: true, Name: l})
+	}
+	return gates
+}
+
+// gatesMetadata returns labels and annotations to set on the handshake object for the gates
+func gatesMetadata(gates []Gate) (map[string]string, map[string]string) {
+	labels, annotations := map[string]string{}, map[string]string{}
+	for _, g := range gates {
+		if g.Label {
+			labels[g.Name] = handshake.LegacyValue
+		} else {
+			annotations[g.Name] = handshake.LegacyValue
+		}
+	}
+	return labels, annotations
+}
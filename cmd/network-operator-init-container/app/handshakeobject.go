@@ -0,0 +1,159 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+// newHandshakeObject returns the object which holds the gates, the Node object or,
+// in the configMap handshake object mode, the per-node ConfigMap in the operator namespace
+func newHandshakeObject(opts *options.Options, cfg configPgk.SafeDriverLoadConfig) client.Object {
+	if cfg.GetHandshakeObject() != configPgk.HandshakeObjectConfigMap {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: opts.NodeName}}
+	}
+	namespace := cfg.HandshakeNamespace
+	if namespace == "" {
+		namespace = opts.ConfigMapNamespace
+	}
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      componentName + "-" + opts.NodeName,
+		Namespace: namespace,
+		Labels:    map[string]string{corev1.LabelHostname: opts.NodeName},
+	}}
+}
+
+// isNodeHandshakeObject returns true if the gates are set on the Node object
+func isNodeHandshakeObject(obj client.Object) bool {
+	_, isNode := obj.(*corev1.Node)
+	return isNode
+}
+
+// handshakeObjectDescription returns description of the handshake object for logs
+func handshakeObjectDescription(obj client.Object) string {
+	if isNodeHandshakeObject(obj) {
+		return "Node " + obj.GetName()
+	}
+	return "ConfigMap " + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// newHandshakeConfigMapCache returns cache which watches only the handshake ConfigMap,
+// the watch is restricted to the namespace and to the name of the ConfigMap with the field selector,
+// the same way as the Node object is watched by the cache of the manager
+func newHandshakeConfigMapCache(mgr ctrl.Manager, obj client.Object) (cache.Cache, error) {
+	return cache.New(mgr.GetConfig(), cache.Options{
+		HTTPClient:        mgr.GetHTTPClient(),
+		Scheme:            mgr.GetScheme(),
+		Mapper:            mgr.GetRESTMapper(),
+		DefaultNamespaces: map[string]cache.Config{obj.GetNamespace(): {}},
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", obj.GetName())}},
+	})
+}
+
+// handshakeConfigMapWaiter watches the handshake ConfigMap until all gates are removed,
+// the ConfigMap is read from the cache which is started by the waiter.
+// Implements manager.Runnable
+type handshakeConfigMapWaiter struct {
+	Cache  cache.Cache
+	Object client.Object
+	// returns gates which are still set on the object
+	Pending func(obj metav1.Object) []string
+	ErrCh   chan error
+	Logger  logr.Logger
+}
+
+// Start watches the ConfigMap until all gates are removed or ctx is canceled,
+// the result is written to ErrCh
+func (w *handshakeConfigMapWaiter) Start(ctx context.Context) error {
+	logger := w.Logger.WithValues("configMap", w.Object.GetNamespace()+"/"+w.Object.GetName())
+	informer, err := w.Cache.GetInformer(ctx, &corev1.ConfigMap{}, cache.BlockUntilSynced(false))
+	if err != nil {
+		logger.Error(err, "failed to get handshake ConfigMap informer")
+		writeCh(w.ErrCh, err)
+		return nil
+	}
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify() },
+		UpdateFunc: func(interface{}, interface{}) { notify() },
+		DeleteFunc: func(interface{}) { notify() },
+	}); err != nil {
+		logger.Error(err, "failed to watch handshake ConfigMap")
+		writeCh(w.ErrCh, err)
+		return nil
+	}
+	go func() {
+		if err := w.Cache.Start(ctx); err != nil {
+			logger.Error(err, "handshake ConfigMap cache failed")
+			writeCh(w.ErrCh, err)
+		}
+	}()
+	if !w.Cache.WaitForCacheSync(ctx) {
+		return nil
+	}
+	for {
+		done, err := w.check(ctx)
+		if err != nil || done {
+			writeCh(w.ErrCh, err)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+	}
+}
+
+// check reads the ConfigMap from the cache, returns true if all gates are removed
+func (w *handshakeConfigMapWaiter) check(ctx context.Context) (bool, error) {
+	logger := w.Logger.WithValues("configMap", w.Object.GetNamespace()+"/"+w.Object.GetName())
+	cm := &corev1.ConfigMap{}
+	if err := w.Cache.Get(ctx, client.ObjectKeyFromObject(w.Object), cm); err != nil {
+		if apiErrors.IsNotFound(err) {
+			return false, fmt.Errorf("handshake ConfigMap %s/%s was deleted while waiting for the gate",
+				w.Object.GetNamespace(), w.Object.GetName())
+		}
+		logger.Error(err, "failed to read handshake ConfigMap from the cache")
+		return false, err
+	}
+	pending := w.Pending(cm)
+	if len(pending) != 0 {
+		logger.Info("annotation still present, waiting", "pending", pending)
+		return false, nil
+	}
+	logger.Info("annotation removed, unblock loading")
+	return true, nil
+}
@@ -0,0 +1,87 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/status"
+)
+
+// addHealthChecks adds liveness and readiness checks to the health probe server of the manager
+func addHealthChecks(mgr ctrl.Manager, tracker *status.Tracker, nodeWatch *nodeWatchSyncedCheck) error {
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("config-loaded", tracker.ConfigLoadedCheck); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("node-watch-sync", nodeWatch.Check); err != nil {
+		return err
+	}
+	return mgr.AddReadyzCheck("phase", tracker.PhaseCheck(string(phaseWait), string(phaseWaitCondition),
+		string(phaseWaitNodeMaintenance), string(phaseWaitForWorkloads), string(phaseRelease), string(phaseDone)))
+}
+
+// addStatusServer adds server with /status endpoint to the manager
+func addStatusServer(mgr ctrl.Manager, bindAddress string, tracker *status.Tracker) error {
+	if bindAddress == "" || bindAddress == "0" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", bindAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", bindAddress, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/status", tracker)
+	return mgr.Add(&manager.Server{
+		Name:     "status",
+		Server:   &http.Server{Handler: mux, ReadHeaderTimeout: 32 * time.Second},
+		Listener: ln,
+	})
+}
+
+// nodeWatchSyncedCheck fails until the Node informer of the Node controller is synced,
+// the check passes if the Node controller is not set up, e.g. in the ConfigMap handshake object
+// or NodeMaintenance wait mode. The check doesn't create the informer itself
+type nodeWatchSyncedCheck struct {
+	lock     sync.Mutex
+	informer cache.Informer
+}
+
+// SetInformer sets the Node informer which is used by the Node controller
+func (c *nodeWatchSyncedCheck) SetInformer(informer cache.Informer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.informer = informer
+}
+
+// Check implements healthz.Checker
+func (c *nodeWatchSyncedCheck) Check(_ *http.Request) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.informer != nil && !c.informer.HasSynced() {
+		return fmt.Errorf("node watch is not synced")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/handshake"
+)
+
+// heartbeat periodically renews the heartbeat timestamp in the annotation payload,
+// implements manager.Runnable
+type heartbeat struct {
+	// client to read the handshake object, can be backed by the cache
+	Reader client.Reader
+	// client to patch the handshake object
+	Writer client.Client
+	// the handshake object, the Node object or the per-node ConfigMap
+	Object     client.Object
+	Annotation string
+	Interval   time.Duration
+	Logger     logr.Logger
+}
+
+// Start renews the heartbeat until ctx is canceled
+func (h *heartbeat) Start(ctx context.Context) error {
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			h.renew(ctx)
+		}
+	}
+}
+
+// renew sets the heartbeat timestamp in the annotation payload,
+// does nothing if the annotation was removed
+func (h *heartbeat) renew(ctx context.Context) {
+	logger := h.Logger.WithValues("annotation", h.Annotation, "object", handshakeObjectDescription(h.Object))
+	obj := h.Object.DeepCopyObject().(client.Object)
+	if err := h.Reader.Get(ctx, client.ObjectKeyFromObject(h.Object), obj); err != nil {
+		logger.Error(err, "failed to read handshake object, skip heartbeat")
+		return
+	}
+	value := obj.GetAnnotations()[h.Annotation]
+	if value == "" {
+		return
+	}
+	payload, err := handshake.Decode(value)
+	if err != nil {
+		logger.Error(err, "failed to decode annotation payload, skip heartbeat")
+		return
+	}
+	payload.Heartbeat = &metav1.Time{Time: time.Now()}
+	newValue, err := payload.Encode()
+	if err != nil {
+		logger.Error(err, "failed to encode annotation payload, skip heartbeat")
+		return
+	}
+	err = replaceAnnotation(ctx, h.Writer, h.Object, h.Annotation, value, newValue)
+	if err != nil {
+		if apiErrors.IsInvalid(err) {
+			logger.V(1).Info("annotation changed concurrently, skip heartbeat")
+			return
+		}
+		logger.Error(err, "failed to renew heartbeat")
+		return
+	}
+	logger.V(1).Info("heartbeat renewed")
+}
@@ -0,0 +1,123 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	"github.com/Mellanox/network-operator-init-container/pkg/preflight"
+)
+
+// resolveNodeNameFromPod sets the node name and, if not set, the pod UID from the Pod object
+// in which this app runs, it is a no-op if the node name is already known
+func resolveNodeNameFromPod(ctx context.Context, logger logr.Logger, k8sClient client.Client,
+	opts *options.Options) error {
+	if opts.NodeName != "" || !opts.NodeNameFromPod {
+		return nil
+	}
+	logger = logger.WithValues("pod", opts.PodNamespace+"/"+opts.PodName)
+	pod := &corev1.Pod{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Namespace: opts.PodNamespace, Name: opts.PodName},
+		pod); err != nil {
+		logger.Error(err, "failed to read pod object from the API")
+		return err
+	}
+	if pod.Spec.NodeName == "" {
+		err := fmt.Errorf("pod %s/%s is not scheduled to a node", opts.PodNamespace, opts.PodName)
+		logger.Error(err, "failed to detect node name")
+		return err
+	}
+	opts.NodeName = pod.Spec.NodeName
+	if opts.IdentitySources == nil {
+		opts.IdentitySources = map[string]string{}
+	}
+	opts.IdentitySources["node-name"] = options.SourcePod
+	if opts.PodUID == "" {
+		opts.PodUID = string(pod.UID)
+		opts.IdentitySources["pod-uid"] = options.SourcePod
+	}
+	logger.Info("node name detected from the pod", "node", opts.NodeName)
+	return nil
+}
+
+// podEventRecorder records every event also for the pod in which this app runs,
+// so the events are shown in the pod description
+type podEventRecorder struct {
+	record.EventRecorder
+	pod *corev1.Pod
+}
+
+// newEventRecorder returns recorder which mirrors events to the pod if the pod is known
+func newEventRecorder(recorder record.EventRecorder, opts *options.Options) record.EventRecorder {
+	if opts.PodName == "" {
+		return recorder
+	}
+	return &podEventRecorder{EventRecorder: recorder, pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: opts.PodName, Namespace: opts.PodNamespace, UID: types.UID(opts.PodUID)}}}
+}
+
+// Event is part of record.EventRecorder interface
+func (r *podEventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.EventRecorder.Event(object, eventtype, reason, message)
+	r.EventRecorder.Event(r.pod, eventtype, reason, message)
+}
+
+// Eventf is part of record.EventRecorder interface
+func (r *podEventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	r.EventRecorder.Eventf(r.pod, eventtype, reason, messageFmt, args...)
+}
+
+// AnnotatedEventf is part of record.EventRecorder interface
+func (r *podEventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string,
+	eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+	r.EventRecorder.AnnotatedEventf(r.pod, annotations, eventtype, reason, messageFmt, args...)
+}
+
+// identityDescription returns description of the node and the pod for messages
+func identityDescription(opts *options.Options) string {
+	if opts.PodName == "" {
+		return "node " + opts.NodeName
+	}
+	return "node " + opts.NodeName + ", pod " + opts.PodNamespace + "/" + opts.PodName
+}
+
+// writeTerminationMessage writes the reason of the failure and the identity of the node and the pod
+// to the termination message file, the reason of the preflight failure is used if the preflight failed
+func writeTerminationMessage(logger logr.Logger, opts *options.Options, err error) {
+	if opts.TerminationMessagePath == "" || err == nil {
+		return
+	}
+	preflightErr := &preflight.Error{}
+	if errors.As(err, &preflightErr) {
+		err = preflightErr
+	}
+	msg := fmt.Sprintf("%s (%s)", err.Error(), identityDescription(opts))
+	if err := os.WriteFile(opts.TerminationMessagePath, []byte(msg), 0o644); err != nil {
+		logger.Error(err, "failed to write termination message", "path", opts.TerminationMessagePath)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/inventory"
+)
+
+// runDeviceInventory discovers NVIDIA/Mellanox devices on the host and publishes
+// the inventory on the Node object, returns discovered devices
+func runDeviceInventory(ctx context.Context, logger logr.Logger, k8sClient client.Client,
+	opts *options.Options, cfg configPgk.DeviceInventoryConfig) ([]inventory.Device, error) {
+	devices, err := inventory.Discover(opts.HostRoot)
+	if err != nil {
+		logger.Error(err, "failed to discover network devices")
+		return nil, err
+	}
+	logger.Info("network devices discovered", "devices", devices)
+	if cfg.Annotation == "" {
+		return devices, nil
+	}
+	data, err := json.Marshal(devices)
+	if err != nil {
+		return nil, err
+	}
+	if err := setNodeAnnotations(ctx, k8sClient, fieldManagerInventory, opts.NodeName,
+		map[string]string{cfg.Annotation: string(data)}); err != nil {
+		logger.Error(err, "failed to publish device inventory", "node", opts.NodeName)
+		return nil, err
+	}
+	return devices, nil
+}
@@ -0,0 +1,203 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+// nodeLockName returns name of the Lease which is used as the lock for the Node
+func nodeLockName(nodeName string) string {
+	return componentName + "-" + nodeName
+}
+
+// nodeLockIdentity returns identity of the lock holder,
+// the pod name is used if known, otherwise the hostname with a random suffix
+func nodeLockIdentity(opts *options.Options) string {
+	if opts.PodName != "" {
+		return opts.PodNamespace + "/" + opts.PodName
+	}
+	hostname, _ := os.Hostname()
+	return hostname + "_" + string(uuid.NewUUID())
+}
+
+// errNodeLockLost is the cause of the run context cancellation if the node lock is lost
+var errNodeLockLost = errors.New("node lock lost")
+
+// acquireNodeLock acquires the per-node Lease and keeps renewing it in the background,
+// the returned function releases the lock and should be called when the init container exits.
+// If the lock is held by another instance, acquireNodeLock waits for it or fails according to the policy.
+// The returned context is canceled with errNodeLockLost as the cause if the lock is lost after it was acquired,
+// it should be used for all steps which require the lock.
+func acquireNodeLock(ctx context.Context, logger logr.Logger, k8sClient client.Client, opts *options.Options,
+	cfg configPgk.NodeLockConfig) (context.Context, func(), error) {
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = opts.ConfigMapNamespace
+	}
+	identity := nodeLockIdentity(opts)
+	logger = logger.WithValues("lease", namespace+"/"+nodeLockName(opts.NodeName), "identity", identity)
+
+	leaseDuration := cfg.GetLeaseDuration()
+	lockCtx, cFunc := context.WithCancel(ctx)
+	lockedCtx, lockedCFunc := context.WithCancelCause(ctx)
+	acquired := make(chan struct{})
+	heldByOther := make(chan string, 1)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &leaseLock{
+			client:   k8sClient,
+			key:      client.ObjectKey{Name: nodeLockName(opts.NodeName), Namespace: namespace},
+			identity: identity,
+		},
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   leaseDuration * 2 / 3,
+		RetryPeriod:     leaseDuration / 5,
+		ReleaseOnCancel: true,
+		Name:            nodeLockName(opts.NodeName),
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) { close(acquired) },
+			OnStoppedLeading: func() {
+				if lockCtx.Err() == nil {
+					logger.Error(errNodeLockLost, "the lock is required to change the node, cancel")
+					lockedCFunc(errNodeLockLost)
+				}
+			},
+			OnNewLeader: func(holder string) {
+				if holder != "" && holder != identity {
+					select {
+					case heldByOther <- holder:
+					default:
+					}
+				}
+			},
+		},
+	})
+	if err != nil {
+		cFunc()
+		lockedCFunc(nil)
+		logger.Error(err, "failed to create node lock")
+		return nil, nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(lockCtx)
+	}()
+	release := func() {
+		cFunc()
+		<-done
+		lockedCFunc(nil)
+		logger.Info("node lock released")
+	}
+
+	logger.Info("acquire node lock", "policy", cfg.Policy)
+	for {
+		select {
+		case <-ctx.Done():
+			release()
+			return nil, nil, fmt.Errorf("node lock acquisition canceled")
+		case holder := <-heldByOther:
+			if cfg.Policy == configPgk.NodeLockPolicyFail {
+				release()
+				err := fmt.Errorf("node lock is held by %s, another instance of the init container "+
+					"is running on the node", holder)
+				logger.Error(err, "failed to acquire node lock")
+				return nil, nil, err
+			}
+			logger.Info("node lock is held by another instance, wait", "holder", holder)
+		case <-acquired:
+			logger.Info("node lock acquired")
+			return lockedCtx, release, nil
+		}
+	}
+}
+
+// leaseLock is resourcelock.Interface implementation for the Lease which uses the controller-runtime client,
+// so the requests of the lock go through the same retry policy and API metrics as other requests
+type leaseLock struct {
+	client   client.Client
+	key      client.ObjectKey
+	identity string
+	lease    *coordinationv1.Lease
+}
+
+// Get returns the election record from the Lease spec
+func (l *leaseLock) Get(ctx context.Context) (*resourcelock.LeaderElectionRecord, []byte, error) {
+	lease := &coordinationv1.Lease{}
+	if err := l.client.Get(ctx, l.key, lease); err != nil {
+		return nil, nil, err
+	}
+	l.lease = lease
+	record := resourcelock.LeaseSpecToLeaderElectionRecord(&lease.Spec)
+	recordByte, err := json.Marshal(*record)
+	if err != nil {
+		return nil, nil, err
+	}
+	return record, recordByte, nil
+}
+
+// Create creates the Lease
+func (l *leaseLock) Create(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: l.key.Name, Namespace: l.key.Namespace},
+		Spec:       resourcelock.LeaderElectionRecordToLeaseSpec(&ler),
+	}
+	if err := l.client.Create(ctx, lease); err != nil {
+		return err
+	}
+	l.lease = lease
+	return nil
+}
+
+// Update updates spec of the existing Lease
+func (l *leaseLock) Update(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	if l.lease == nil {
+		return errors.New("lease not initialized, call get or create first")
+	}
+	lease := l.lease.DeepCopy()
+	lease.Spec = resourcelock.LeaderElectionRecordToLeaseSpec(&ler)
+	if err := l.client.Update(ctx, lease); err != nil {
+		return err
+	}
+	l.lease = lease
+	return nil
+}
+
+// RecordEvent does nothing, events of the lock are not recorded
+func (l *leaseLock) RecordEvent(string) {}
+
+// Identity returns identity of the lock holder
+func (l *leaseLock) Identity() string {
+	return l.identity
+}
+
+// Describe returns the Lease name
+func (l *leaseLock) Describe() string {
+	return l.key.String()
+}
@@ -0,0 +1,116 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// field managers of the init container, fields of objects which are set with server-side apply
+// are owned by the field manager until they are removed, every feature uses its own field manager
+// because server-side apply removes fields which are owned by the field manager but not included in the request
+const (
+	fieldManager          = componentName
+	fieldManagerInventory = componentName + "-inventory"
+	fieldManagerPreflight = componentName + "-preflight"
+)
+
+// setNodeAnnotations sets annotations on the Node object with server-side apply
+func setNodeAnnotations(ctx context.Context, k8sClient client.Client, manager, nodeName string,
+	annotations map[string]string) error {
+	return setObjectMetadata(ctx, k8sClient, manager,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}, nil, annotations)
+}
+
+// setObjectMetadata sets labels and annotations on the object with server-side apply,
+// the object is created if it doesn't exist. The request fails with Conflict error
+// if resourceVersion of obj is not empty and the object has been changed
+func setObjectMetadata(ctx context.Context, k8sClient client.Client, manager string, obj client.Object,
+	labels, annotations map[string]string) error {
+	gvk, err := apiutil.GVKForObject(obj, k8sClient.Scheme())
+	if err != nil {
+		return err
+	}
+	patch := &unstructured.Unstructured{}
+	patch.SetGroupVersionKind(gvk)
+	patch.SetName(obj.GetName())
+	patch.SetNamespace(obj.GetNamespace())
+	patch.SetResourceVersion(obj.GetResourceVersion())
+	patch.SetLabels(labels)
+	patch.SetAnnotations(annotations)
+	return k8sClient.Patch(ctx, patch, client.Apply, client.FieldOwner(manager), client.ForceOwnership)
+}
+
+// removeAnnotation removes the annotation from the object with JSON merge patch
+func removeAnnotation(ctx context.Context, k8sClient client.Client, obj client.Object, annotation string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{annotation: nil}}})
+	if err != nil {
+		return err
+	}
+	return k8sClient.Patch(ctx, obj.DeepCopyObject().(client.Object),
+		client.RawPatch(types.MergePatchType, patch), client.FieldOwner(fieldManager))
+}
+
+// cordonAnnotation marks the Node which was cordoned by the container,
+// the Node is uncordoned only if the annotation is present, e.g. after a restart of the container
+const cordonAnnotation = componentName + "/cordoned"
+
+// setNodeUnschedulable cordons or uncordons the Node object with JSON merge patch,
+// cordonAnnotation is set together with the cordon and removed together with the uncordon
+func setNodeUnschedulable(ctx context.Context, k8sClient client.Client, nodeName string, unschedulable bool) error {
+	var marker interface{}
+	if unschedulable {
+		marker = "true"
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{cordonAnnotation: marker}},
+		"spec":     map[string]interface{}{"unschedulable": unschedulable}})
+	if err != nil {
+		return err
+	}
+	return k8sClient.Patch(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}},
+		client.RawPatch(types.MergePatchType, patch), client.FieldOwner(fieldManager))
+}
+
+// replaceAnnotation replaces value of the annotation on the object with JSON patch,
+// the patch fails with Invalid error if the current value of the annotation is not equal to oldValue,
+// e.g. the annotation was removed
+func replaceAnnotation(ctx context.Context, k8sClient client.Client, obj client.Object,
+	annotation, oldValue, newValue string) error {
+	path := "/metadata/annotations/" + escapeJSONPointer(annotation)
+	patch, err := json.Marshal([]map[string]string{
+		{"op": "test", "path": path, "value": oldValue},
+		{"op": "replace", "path": path, "value": newValue},
+	})
+	if err != nil {
+		return err
+	}
+	return k8sClient.Patch(ctx, obj.DeepCopyObject().(client.Object),
+		client.RawPatch(types.JSONPatchType, patch), client.FieldOwner(fieldManager))
+}
+
+// escapeJSONPointer escapes the reference token for JSON pointer, see RFC 6901
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
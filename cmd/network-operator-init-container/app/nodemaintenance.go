@@ -0,0 +1,198 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+const (
+	nodeMaintenancePollInterval  = 5 * time.Second
+	nodeMaintenanceDeleteTimeout = 10 * time.Second
+	// timeout for deletion of the existing object which is recreated, e.g. to let finalizers complete
+	nodeMaintenanceRecreateTimeout = time.Minute
+	nodeMaintenanceReadyType       = "Ready"
+)
+
+// newNodeMaintenance returns NodeMaintenance object for the Node
+func newNodeMaintenance(nodeName string, cfg configPgk.NodeMaintenanceConfig) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetGroupVersionKind(cfg.GetGroupVersionKind())
+	obj.SetNamespace(cfg.GetNamespace())
+	obj.SetName(componentName + "-" + nodeName)
+	spec := map[string]interface{}{}
+	if cfg.Spec != nil {
+		spec = runtime.DeepCopyJSON(cfg.Spec)
+	}
+	spec["nodeName"] = nodeName
+	spec["requestorID"] = cfg.GetRequestorID()
+	obj.Object["spec"] = spec
+	return obj
+}
+
+// createNodeMaintenance creates NodeMaintenance object, the existing object is taken over.
+// The spec of the existing object is updated if it differs from the desired one, the object is recreated
+// if it is being deleted or has another nodeName or requestorID, the fields are immutable
+func createNodeMaintenance(ctx context.Context, logger logr.Logger, k8sClient client.Client,
+	obj *unstructured.Unstructured) error {
+	logger = logger.WithValues("kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName())
+	err := k8sClient.Create(ctx, obj.DeepCopy())
+	if err == nil {
+		logger.Info("NodeMaintenance object created")
+		return nil
+	}
+	if !apiErrors.IsAlreadyExists(err) {
+		logger.Error(err, "failed to create NodeMaintenance object")
+		return err
+	}
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		logger.Error(err, "failed to read existing NodeMaintenance object")
+		return err
+	}
+	existingSpec, _, _ := unstructured.NestedMap(existing.Object, "spec")
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	switch {
+	case existing.GetDeletionTimestamp() != nil ||
+		existingSpec["nodeName"] != spec["nodeName"] || existingSpec["requestorID"] != spec["requestorID"]:
+		logger.Info("NodeMaintenance object already exists with another node name or requestor ID "+
+			"or is being deleted, recreate", "nodeName", existingSpec["nodeName"],
+			"requestorID", existingSpec["requestorID"])
+		return recreateNodeMaintenance(ctx, logger, k8sClient, existing, obj)
+	case equality.Semantic.DeepEqual(existingSpec, spec):
+		logger.Info("NodeMaintenance object already exists, take over")
+	default:
+		existing.Object["spec"] = runtime.DeepCopyJSON(spec)
+		if err := k8sClient.Update(ctx, existing); err != nil {
+			logger.Error(err, "failed to update spec of existing NodeMaintenance object")
+			return err
+		}
+		logger.Info("NodeMaintenance object already exists, spec updated, take over")
+	}
+	return nil
+}
+
+// recreateNodeMaintenance deletes the existing NodeMaintenance object, waits until it is gone
+// and creates the desired object
+func recreateNodeMaintenance(ctx context.Context, logger logr.Logger, k8sClient client.Client,
+	existing, obj *unstructured.Unstructured) error {
+	if existing.GetDeletionTimestamp() == nil {
+		err := k8sClient.Delete(ctx, existing, client.Preconditions{UID: ptr.To(existing.GetUID())})
+		if client.IgnoreNotFound(err) != nil && !apiErrors.IsConflict(err) {
+			logger.Error(err, "failed to delete existing NodeMaintenance object")
+			return err
+		}
+	}
+	err := wait.PollUntilContextTimeout(ctx, time.Second, nodeMaintenanceRecreateTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			current := &unstructured.Unstructured{}
+			current.SetGroupVersionKind(obj.GroupVersionKind())
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), current)
+			if apiErrors.IsNotFound(err) || (err == nil && current.GetUID() != existing.GetUID()) {
+				return true, nil
+			}
+			return false, err
+		})
+	if err != nil {
+		logger.Error(err, "failed to wait for deletion of existing NodeMaintenance object")
+		return err
+	}
+	if err := k8sClient.Create(ctx, obj.DeepCopy()); err != nil {
+		logger.Error(err, "failed to create NodeMaintenance object")
+		return err
+	}
+	logger.Info("NodeMaintenance object recreated")
+	return nil
+}
+
+// deleteNodeMaintenance deletes NodeMaintenance object, errors are logged and ignored
+func deleteNodeMaintenance(logger logr.Logger, k8sClient client.Client, obj *unstructured.Unstructured) {
+	logger = logger.WithValues("kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName())
+	// ctx of the init container can be already canceled
+	ctx, cFunc := context.WithTimeout(context.Background(), nodeMaintenanceDeleteTimeout)
+	defer cFunc()
+	if err := k8sClient.Delete(ctx, obj.DeepCopy()); client.IgnoreNotFound(err) != nil {
+		logger.Error(err, "failed to delete NodeMaintenance object")
+		return
+	}
+	logger.Info("NodeMaintenance object deleted")
+}
+
+// nodeMaintenanceWaiter waits for Ready condition of the NodeMaintenance object,
+// implements manager.Runnable
+type nodeMaintenanceWaiter struct {
+	Client   client.Client
+	Object   *unstructured.Unstructured
+	Interval time.Duration
+	ErrCh    chan error
+	Logger   logr.Logger
+}
+
+// Start polls the NodeMaintenance object until it is ready, deleted or ctx is canceled
+func (w *nodeMaintenanceWaiter) Start(ctx context.Context) error {
+	logger := w.Logger.WithValues("kind", w.Object.GetKind(),
+		"namespace", w.Object.GetNamespace(), "name", w.Object.GetName())
+	err := wait.PollUntilContextCancel(ctx, w.Interval, true, func(ctx context.Context) (bool, error) {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(w.Object.GroupVersionKind())
+		if err := w.Client.Get(ctx, client.ObjectKeyFromObject(w.Object), obj); err != nil {
+			if apiErrors.IsNotFound(err) {
+				return false, fmt.Errorf("NodeMaintenance object %s/%s was deleted",
+					w.Object.GetNamespace(), w.Object.GetName())
+			}
+			logger.Error(err, "failed to read NodeMaintenance object")
+			return false, nil
+		}
+		if !isNodeMaintenanceReady(obj) {
+			logger.Info("NodeMaintenance object is not ready, waiting")
+			return false, nil
+		}
+		logger.Info("NodeMaintenance object is ready, unblock loading")
+		return true, nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	writeCh(w.ErrCh, err)
+	return nil
+}
+
+// isNodeMaintenanceReady returns true if the object has Ready condition with True status
+func isNodeMaintenanceReady(obj *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == nodeMaintenanceReadyType && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
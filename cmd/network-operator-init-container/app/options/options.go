@@ -16,40 +16,143 @@ package options
 import (
 	goflag "flag"
 	"fmt"
+	"os"
+	"time"
 
 	cliflag "k8s.io/component-base/cli/flag"
 	"k8s.io/component-base/logs"
 	logsapi "k8s.io/component-base/logs/api/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/retry"
+)
+
+const (
+	// DefaultKubeAPIQPS is the default QPS of the client for the Kubernetes API
+	DefaultKubeAPIQPS = 20
+	// DefaultKubeAPIBurst is the default burst of the client for the Kubernetes API
+	DefaultKubeAPIBurst = 30
+)
+
+// environment variables which are used if the corresponding parameters are not set,
+// the variables are usually set from the downward API
+const (
+	EnvNodeName     = "NODE_NAME"
+	EnvPodName      = "POD_NAME"
+	EnvPodNamespace = "POD_NAMESPACE"
+	EnvPodUID       = "POD_UID"
+)
+
+// sources of the node name and pod identity parameters
+const (
+	SourceFlag = "flag"
+	SourceEnv  = "env"
+	SourcePod  = "pod"
 )
 
 // New creates new Options
 func New() *Options {
 	return &Options{
-		LogConfig: logsapi.NewLoggingConfiguration(),
+		LogConfig:              logsapi.NewLoggingConfiguration(),
+		HostRoot:               "/host",
+		TerminationMessagePath: "/dev/termination-log",
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: "0",
+		StatusBindAddress:      "0",
+		APIRetryAttempts:       retry.DefaultAttempts,
+		APIRetryBackoff:        retry.DefaultBackoff,
+		APIRetryMaxBackoff:     retry.DefaultMaxBackoff,
+		KubeAPIQPS:             DefaultKubeAPIQPS,
+		KubeAPIBurst:           DefaultKubeAPIBurst,
 	}
 }
 
 // Options contains application options
 type Options struct {
-	NodeName           string
-	ConfigMapName      string
-	ConfigMapNamespace string
-	ConfigMapKey       string
-	LogConfig          *logsapi.LoggingConfiguration
+	NodeName               string
+	NodeNameFromPod        bool
+	PodName                string
+	PodNamespace           string
+	PodUID                 string
+	ConfigMapName          string
+	ConfigMapNamespace     string
+	ConfigMapKey           string
+	HostRoot               string
+	TerminationMessagePath string
+	MetricsBindAddress     string
+	HealthProbeBindAddress string
+	StatusBindAddress      string
+	TracingEndpoint        string
+	APIRetryAttempts       int
+	APIRetryBackoff        time.Duration
+	APIRetryMaxBackoff     time.Duration
+	KubeAPIQPS             float32
+	KubeAPIBurst           int
+	KubeAPIProtobuf        bool
+	StartupJitter          time.Duration
+	LogConfig              *logsapi.LoggingConfiguration
+	// sources of the node name and pod identity parameters by parameter name, set by Validate
+	IdentitySources map[string]string
 }
 
 // AddNamedFlagSets returns FlagSet for Options
 func (o *Options) AddNamedFlagSets(sharedFS *cliflag.NamedFlagSets) {
 	configFS := sharedFS.FlagSet("Config")
 	configFS.StringVar(&o.NodeName, "node-name", "",
-		"name of the k8s node on which this app runs")
+		"name of the k8s node on which this app runs, "+EnvNodeName+" environment variable is used if not set")
+	configFS.BoolVar(&o.NodeNameFromPod, "node-name-from-pod", false,
+		"read the node name from spec.nodeName of the pod if node-name is not set, requires pod-name and pod-namespace")
+	configFS.StringVar(&o.PodName, "pod-name", "",
+		"name of the pod in which this app runs, used to detect stale annotations, optional, "+
+			EnvPodName+" environment variable is used if not set")
+	configFS.StringVar(&o.PodNamespace, "pod-namespace", "",
+		"namespace of the pod in which this app runs, required if pod-name is set, "+
+			EnvPodNamespace+" environment variable is used if not set")
+	configFS.StringVar(&o.PodUID, "pod-uid", "",
+		"UID of the pod in which this app runs, optional, "+EnvPodUID+" environment variable is used if not set")
 	configFS.StringVar(&o.ConfigMapName, "configmap-name", "",
 		"name of the configmap with configuration for the app")
 	configFS.StringVar(&o.ConfigMapNamespace, "configmap-namespace", "",
 		"namespace of the configmap with configuration for the app")
 	configFS.StringVar(&o.ConfigMapKey, "configmap-key", "config.json",
 		"key inside the configmap with configuration for the app")
+	configFS.StringVar(&o.HostRoot, "host-root", o.HostRoot,
+		"path at which the root filesystem of the host is mounted")
+	configFS.StringVar(&o.TerminationMessagePath, "termination-message-path", o.TerminationMessagePath,
+		"path to the file to which the reason of a failure is written, empty value disables writing")
+
+	endpointsFS := sharedFS.FlagSet("Endpoints")
+	endpointsFS.StringVar(&o.MetricsBindAddress, "metrics-bind-address", o.MetricsBindAddress,
+		"the address the metric endpoint binds to, e.g. :8080, use 0 to disable the metrics endpoint")
+	endpointsFS.StringVar(&o.HealthProbeBindAddress, "health-probe-bind-address", o.HealthProbeBindAddress,
+		"the address the /healthz and /readyz health probe endpoints bind to, e.g. :8081, "+
+			"use 0 to disable the endpoints")
+	endpointsFS.StringVar(&o.StatusBindAddress, "status-bind-address", o.StatusBindAddress,
+		"the address the /status endpoint binds to, e.g. :8082, use 0 to disable the endpoint")
+
+	apiFS := sharedFS.FlagSet("API")
+	apiFS.IntVar(&o.APIRetryAttempts, "api-retry-attempts", o.APIRetryAttempts,
+		"maximum number of attempts for requests to the Kubernetes API which fail with transient errors, "+
+			"e.g. timeouts, 429 and 5xx, use 1 to disable retries")
+	apiFS.DurationVar(&o.APIRetryBackoff, "api-retry-backoff", o.APIRetryBackoff,
+		"backoff before the first retry of a request to the Kubernetes API, doubled for every next retry")
+	apiFS.DurationVar(&o.APIRetryMaxBackoff, "api-retry-max-backoff", o.APIRetryMaxBackoff,
+		"maximum backoff between retries of a request to the Kubernetes API")
+	apiFS.Float32Var(&o.KubeAPIQPS, "kube-api-qps", o.KubeAPIQPS,
+		"maximum QPS of the client for the Kubernetes API")
+	apiFS.IntVar(&o.KubeAPIBurst, "kube-api-burst", o.KubeAPIBurst,
+		"maximum burst of the client for the Kubernetes API")
+	apiFS.BoolVar(&o.KubeAPIProtobuf, "kube-api-protobuf", o.KubeAPIProtobuf,
+		"use protobuf content type for requests to the Kubernetes API for built-in types, "+
+			"reduces load on the API server")
+	apiFS.DurationVar(&o.StartupJitter, "startup-jitter", o.StartupJitter,
+		"maximum random delay before the first request to the Kubernetes API, spreads requests "+
+			"of init containers which start at the same time, e.g. on a DaemonSet rollout, use 0 to disable")
+
+	tracingFS := sharedFS.FlagSet("Tracing")
+	tracingFS.StringVar(&o.TracingEndpoint, "tracing-endpoint", o.TracingEndpoint,
+		"OTLP/HTTP endpoint to export traces to, e.g. http://localhost:4318, "+
+			"tracing is disabled if not set and OTEL_EXPORTER_OTLP_ENDPOINT environment variable is empty")
 
 	logFS := sharedFS.FlagSet("Logging")
 	logsapi.AddFlags(o.LogConfig, logFS)
@@ -66,12 +169,55 @@ func (o *Options) AddNamedFlagSets(sharedFS *cliflag.NamedFlagSets) {
 	kubernetesFS.AddGoFlagSet(goFS)
 }
 
+// ApplyEnvFallbacks sets the node name and pod identity parameters which are not set
+// from the environment variables and records sources of the parameters in IdentitySources,
+// it should be called before Validate
+func (o *Options) ApplyEnvFallbacks() {
+	o.IdentitySources = map[string]string{}
+	for _, p := range []struct {
+		name  string
+		env   string
+		value *string
+	}{
+		{name: "node-name", env: EnvNodeName, value: &o.NodeName},
+		{name: "pod-name", env: EnvPodName, value: &o.PodName},
+		{name: "pod-namespace", env: EnvPodNamespace, value: &o.PodNamespace},
+		{name: "pod-uid", env: EnvPodUID, value: &o.PodUID},
+	} {
+		if *p.value != "" {
+			o.IdentitySources[p.name] = SourceFlag
+			continue
+		}
+		if v := os.Getenv(p.env); v != "" {
+			*p.value = v
+			o.IdentitySources[p.name] = SourceEnv + " " + p.env
+		}
+	}
+}
+
+// APIRetryPolicy returns retry policy for requests to the Kubernetes API
+func (o *Options) APIRetryPolicy() retry.Policy {
+	return retry.Policy{Attempts: o.APIRetryAttempts, Backoff: o.APIRetryBackoff, MaxBackoff: o.APIRetryMaxBackoff}
+}
+
 // Validate registered options
 func (o *Options) Validate() error {
 	var err error
 
+	if o.PodName != "" && o.PodNamespace == "" {
+		return fmt.Errorf("pod-namespace is required parameter if pod-name is set, "+
+			"set --pod-namespace or %s environment variable", EnvPodNamespace)
+	}
+
 	if o.NodeName == "" {
-		return fmt.Errorf("node-name is required parameter")
+		if !o.NodeNameFromPod {
+			return fmt.Errorf("node-name is required parameter, set --node-name, %s environment variable "+
+				"or --node-name-from-pod", EnvNodeName)
+		}
+		if o.PodName == "" {
+			return fmt.Errorf("node-name-from-pod requires pod-name, set --pod-name or %s environment variable",
+				EnvPodName)
+		}
 	}
 
 	if o.ConfigMapName == "" {
@@ -86,6 +232,26 @@ func (o *Options) Validate() error {
 		return fmt.Errorf("configmap-key is required parameter")
 	}
 
+	if o.HostRoot == "" {
+		return fmt.Errorf("host-root is required parameter")
+	}
+
+	if o.APIRetryAttempts < 1 {
+		return fmt.Errorf("api-retry-attempts should be at least 1")
+	}
+
+	if o.APIRetryBackoff <= 0 || o.APIRetryMaxBackoff < o.APIRetryBackoff {
+		return fmt.Errorf("api-retry-backoff should be positive and not greater than api-retry-max-backoff")
+	}
+
+	if o.KubeAPIQPS < 0 || o.KubeAPIBurst < 0 {
+		return fmt.Errorf("kube-api-qps and kube-api-burst can't be negative")
+	}
+
+	if o.StartupJitter < 0 {
+		return fmt.Errorf("startup-jitter can't be negative")
+	}
+
 	if err = logsapi.ValidateAndApply(o.LogConfig, nil); err != nil {
 		return fmt.Errorf("failed to validate logging flags. %w", err)
 	}
@@ -0,0 +1,91 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package options_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+)
+
+var _ = Describe("Options", func() {
+	var opts *options.Options
+
+	BeforeEach(func() {
+		for _, env := range []string{options.EnvNodeName, options.EnvPodName, options.EnvPodNamespace,
+			options.EnvPodUID} {
+			GinkgoT().Setenv(env, "")
+		}
+		opts = options.New()
+		opts.ConfigMapName = "config"
+		opts.ConfigMapNamespace = "default"
+		opts.ConfigMapKey = "config.json"
+	})
+	It("Valid - flags", func() {
+		opts.NodeName = "node1"
+		opts.PodName = "pod1"
+		opts.PodNamespace = "default"
+		opts.ApplyEnvFallbacks()
+		Expect(opts.Validate()).NotTo(HaveOccurred())
+		Expect(opts.IdentitySources).To(Equal(map[string]string{
+			"node-name": options.SourceFlag, "pod-name": options.SourceFlag, "pod-namespace": options.SourceFlag}))
+	})
+	It("Valid - environment variables", func() {
+		GinkgoT().Setenv(options.EnvNodeName, "node1")
+		GinkgoT().Setenv(options.EnvPodName, "pod1")
+		GinkgoT().Setenv(options.EnvPodNamespace, "default")
+		GinkgoT().Setenv(options.EnvPodUID, "uid1")
+		opts.PodName = "pod2"
+		opts.ApplyEnvFallbacks()
+		Expect(opts.Validate()).NotTo(HaveOccurred())
+		Expect(opts.NodeName).To(Equal("node1"))
+		Expect(opts.PodName).To(Equal("pod2"))
+		Expect(opts.PodNamespace).To(Equal("default"))
+		Expect(opts.PodUID).To(Equal("uid1"))
+		Expect(opts.IdentitySources).To(Equal(map[string]string{
+			"node-name":     options.SourceEnv + " " + options.EnvNodeName,
+			"pod-name":      options.SourceFlag,
+			"pod-namespace": options.SourceEnv + " " + options.EnvPodNamespace,
+			"pod-uid":       options.SourceEnv + " " + options.EnvPodUID,
+		}))
+	})
+	It("Valid - node name from pod", func() {
+		opts.NodeNameFromPod = true
+		opts.PodName = "pod1"
+		opts.PodNamespace = "default"
+		opts.ApplyEnvFallbacks()
+		Expect(opts.Validate()).NotTo(HaveOccurred())
+		Expect(opts.NodeName).To(BeEmpty())
+		Expect(opts.IdentitySources).NotTo(HaveKey("node-name"))
+	})
+	It("Validate doesn't read environment variables", func() {
+		GinkgoT().Setenv(options.EnvNodeName, "node1")
+		Expect(opts.Validate()).To(MatchError(ContainSubstring(options.EnvNodeName)))
+		Expect(opts.NodeName).To(BeEmpty())
+		Expect(opts.IdentitySources).To(BeEmpty())
+	})
+	It("Invalid - no node name", func() {
+		Expect(opts.Validate()).To(MatchError(ContainSubstring(options.EnvNodeName)))
+	})
+	It("Invalid - node name from pod without pod name", func() {
+		opts.NodeNameFromPod = true
+		Expect(opts.Validate()).To(MatchError(ContainSubstring("node-name-from-pod requires pod-name")))
+	})
+	It("Invalid - pod name without namespace", func() {
+		opts.NodeName = "node1"
+		opts.PodName = "pod1"
+		Expect(opts.Validate()).To(MatchError(ContainSubstring(options.EnvPodNamespace)))
+	})
+})
@@ -0,0 +1,74 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+
+	cliflag "k8s.io/component-base/cli/flag"
+	"k8s.io/component-base/logs"
+	logsapi "k8s.io/component-base/logs/api/v1"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/sriov"
+)
+
+// NewRestoreOptions creates new RestoreOptions
+func NewRestoreOptions() *RestoreOptions {
+	return &RestoreOptions{
+		LogConfig: logsapi.NewLoggingConfiguration(),
+		HostRoot:  "/host",
+		StateFile: sriov.DefaultStateFile,
+	}
+}
+
+// RestoreOptions contains options for the restore command
+type RestoreOptions struct {
+	HostRoot  string
+	StateFile string
+	LogConfig *logsapi.LoggingConfiguration
+}
+
+// AddNamedFlagSets returns FlagSet for RestoreOptions
+func (o *RestoreOptions) AddNamedFlagSets(sharedFS *cliflag.NamedFlagSets) {
+	configFS := sharedFS.FlagSet("Config")
+	configFS.StringVar(&o.HostRoot, "host-root", o.HostRoot,
+		"path at which the root filesystem of the host is mounted")
+	configFS.StringVar(&o.StateFile, "state-file", o.StateFile,
+		"path to the file on the host with SR-IOV configuration snapshot")
+
+	logFS := sharedFS.FlagSet("Logging")
+	logsapi.AddFlags(o.LogConfig, logFS)
+	logs.AddFlags(logFS, logs.SkipLoggingConfigurationFlags())
+
+	generalFS := sharedFS.FlagSet("General")
+	_ = generalFS.BoolP("help", "h", false, "print help and exit")
+}
+
+// Validate registered options
+func (o *RestoreOptions) Validate() error {
+	var err error
+
+	if o.HostRoot == "" {
+		return fmt.Errorf("host-root is required parameter")
+	}
+
+	if o.StateFile == "" {
+		return fmt.Errorf("state-file is required parameter")
+	}
+
+	if err = logsapi.ValidateAndApply(o.LogConfig, nil); err != nil {
+		return fmt.Errorf("failed to validate logging flags. %w", err)
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+// permission is a permission which is required by an enabled feature
+type permission struct {
+	// feature which requires the permission
+	Feature string
+	Group   string
+	// resource, with subresource if required, e.g. pods/eviction
+	Resource string
+	Verb     string
+	// namespace for namespaced resources, empty value means all namespaces
+	Namespace string
+}
+
+func (p permission) String() string {
+	s := p.Verb + " " + p.Resource
+	if p.Group != "" {
+		s += "." + p.Group
+	}
+	if p.Namespace != "" {
+		s += " in namespace " + p.Namespace
+	}
+	return s + " (" + p.Feature + ")"
+}
+
+// addPermissions returns perms with permissions for the verbs on the resource
+func addPermissions(perms []permission, feature, group, resource, namespace string, verbs ...string) []permission {
+	for _, verb := range verbs {
+		perms = append(perms, permission{
+			Feature: feature, Group: group, Resource: resource, Verb: verb, Namespace: namespace})
+	}
+	return perms
+}
+
+// addEventPermissions returns perms with permissions to record events for the Node object
+// and, if the pod is known, for the pod in which this app runs
+func addEventPermissions(perms []permission, feature string, opts *options.Options) []permission {
+	perms = addPermissions(perms, feature, "", "events", metav1.NamespaceDefault, "create", "patch")
+	if opts.PodName != "" {
+		perms = addPermissions(perms, feature, "", "events", opts.PodNamespace, "create", "patch")
+	}
+	return perms
+}
+
+// requiredPermissions returns permissions which are required by the enabled features
+func requiredPermissions(k8sClient client.Client, opts *options.Options, cfg *configPgk.Config) []permission {
+	perms := addPermissions(nil, "config", "", "configmaps", opts.ConfigMapNamespace, "get")
+	perms = addPermissions(perms, "node", "", "nodes", "", "get")
+	if opts.IdentitySources["node-name"] == options.SourcePod {
+		perms = addPermissions(perms, "nodeNameFromPod", "", "pods", opts.PodNamespace, "get")
+	}
+
+	preflightCfg := cfg.Preflight
+	if preflightCfg.SecureBoot.Enable || preflightCfg.KernelHeaders.Enable || len(preflightCfg.DiskSpace) != 0 ||
+		len(preflightCfg.Firmware.MinVersions) != 0 {
+		perms = addEventPermissions(perms, "preflight", opts)
+		if preflightCfg.ResultAnnotation != "" {
+			perms = addPermissions(perms, "preflight", "", "nodes", "", "patch")
+		}
+	}
+	if cfg.DeviceInventory.Enable && cfg.DeviceInventory.Annotation != "" {
+		perms = addPermissions(perms, "deviceInventory", "", "nodes", "", "patch")
+	}
+	if cfg.NodeLock.Enable {
+		namespace := cfg.NodeLock.Namespace
+		if namespace == "" {
+			namespace = opts.ConfigMapNamespace
+		}
+		perms = addPermissions(perms, "nodeLock", "coordination.k8s.io", "leases", namespace,
+			"get", "create", "update")
+	}
+	if cfg.WaitForPreviousPod.Enable {
+		perms = addPermissions(perms, "waitForPreviousPod", "", "pods", opts.PodNamespace, "get", "list", "watch")
+		perms = addEventPermissions(perms, "waitForPreviousPod", opts)
+	}
+	if !cfg.SafeDriverLoad.Enable {
+		return perms
+	}
+
+	if cfg.Drain.Enable {
+		perms = addPermissions(perms, "drain", "", "nodes", "", "patch")
+		perms = addPermissions(perms, "drain", "", "pods", "", "list")
+		perms = addPermissions(perms, "drain", "", "pods/eviction", "", "create")
+	}
+	if cfg.SafeDriverLoad.GetWaitMode() == configPgk.WaitModeNodeMaintenance {
+		gvk := cfg.SafeDriverLoad.NodeMaintenance.GetGroupVersionKind()
+		// the resource of NodeMaintenance is known only if the CRD is installed,
+		// the permissions are not checked otherwise and creation of the object fails later
+		if mapping, err := k8sClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			perms = addPermissions(perms, "safeDriverLoad", gvk.Group, mapping.Resource.Resource,
+				cfg.SafeDriverLoad.NodeMaintenance.GetNamespace(), "get", "create", "update", "delete")
+		}
+	} else {
+		// the Node is watched only by the Node controller, the health probe doesn't watch the Node
+		// in the ConfigMap and NodeMaintenance modes, so nodes list and watch are not required there.
+		// The gates are written only in the annotation wait mode, with the annotation or the label gate type,
+		// the condition wait mode only reads the Node object
+		setsGates := cfg.SafeDriverLoad.GetWaitMode() == configPgk.WaitModeAnnotation
+		if handshakeObj := newHandshakeObject(opts, cfg.SafeDriverLoad); isNodeHandshakeObject(handshakeObj) {
+			perms = addPermissions(perms, "safeDriverLoad", "", "nodes", "", "list", "watch")
+			if setsGates {
+				perms = addPermissions(perms, "safeDriverLoad", "", "nodes", "", "patch")
+			}
+		} else {
+			perms = addPermissions(perms, "safeDriverLoad", "", "configmaps", handshakeObj.GetNamespace(),
+				"get", "list", "watch", "create", "patch")
+		}
+		if setsGates && cfg.SafeDriverLoad.Annotation != "" && opts.PodName != "" {
+			// stale annotation detection reads the pod which owns the annotation
+			perms = addPermissions(perms, "safeDriverLoad", "", "pods", opts.PodNamespace, "get")
+		}
+	}
+	if cfg.WaitForWorkloads.Enable {
+		perms = addPermissions(perms, "waitForWorkloads", "", "pods", "", "list", "watch")
+		perms = addEventPermissions(perms, "waitForWorkloads", opts)
+	}
+	return perms
+}
+
+// checkPermissions checks the permissions with SelfSubjectAccessReview and returns
+// a single error which lists all missing permissions and RBAC rules to grant them.
+// The check is skipped with an error in the log if SelfSubjectAccessReview is not allowed or fails
+func checkPermissions(ctx context.Context, logger logr.Logger, k8sClient client.Client, perms []permission) error {
+	missing := []permission{}
+	// the same permission can be required by several features
+	allowed := map[permission]bool{}
+	for _, p := range perms {
+		key := p
+		key.Feature = ""
+		if result, checked := allowed[key]; checked {
+			if !result {
+				missing = append(missing, p)
+			}
+			continue
+		}
+		resource, subresource, _ := strings.Cut(p.Resource, "/")
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   p.Namespace,
+					Verb:        p.Verb,
+					Group:       p.Group,
+					Resource:    resource,
+					Subresource: subresource,
+				},
+			},
+		}
+		if err := k8sClient.Create(ctx, review); err != nil {
+			// the check is best effort, missing permissions are reported by the failed requests later
+			logger.Error(err, "unable to check permissions with SelfSubjectAccessReview, skip the check")
+			return nil
+		}
+		allowed[key] = review.Status.Allowed
+		if !review.Status.Allowed {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		logger.V(1).Info("all required permissions are granted")
+		return nil
+	}
+	descriptions := make([]string, 0, len(missing))
+	for _, p := range missing {
+		descriptions = append(descriptions, p.String())
+	}
+	err := fmt.Errorf("missing permissions: %s, grant them with the following RBAC rules:\n%s",
+		strings.Join(descriptions, ", "), rbacRules(missing))
+	logger.Error(err, "RBAC self-check failed")
+	return err
+}
+
+// rbacRules returns RBAC rules which grant the permissions in the format of the README
+func rbacRules(perms []permission) string {
+	type ruleKey struct{ group, resource string }
+	verbs := map[ruleKey][]string{}
+	keys := []ruleKey{}
+	for _, p := range perms {
+		key := ruleKey{group: p.Group, resource: p.Resource}
+		if _, exist := verbs[key]; !exist {
+			keys = append(keys, key)
+		}
+		if !slices.Contains(verbs[key], p.Verb) {
+			verbs[key] = append(verbs[key], p.Verb)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		return keys[i].resource < keys[j].resource
+	})
+	b := strings.Builder{}
+	for _, key := range keys {
+		fmt.Fprintf(&b, "  - apiGroups: [%q]\n    resources: [%q]\n    verbs: [%s]\n",
+			key.group, key.resource, quoteJoin(verbs[key]))
+	}
+	return b.String()
+}
+
+func quoteJoin(items []string) string {
+	quoted := make([]string, 0, len(items))
+	for _, item := range items {
+		quoted = append(quoted, fmt.Sprintf("%q", item))
+	}
+	return strings.Join(quoted, ", ")
+}
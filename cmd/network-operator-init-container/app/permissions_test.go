@@ -0,0 +1,62 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+var _ = Describe("Required permissions", func() {
+	const (
+		patchNodes = "patch nodes (safeDriverLoad)"
+		watchNodes = "watch nodes (safeDriverLoad)"
+		getPod     = "get pods in namespace default (safeDriverLoad)"
+	)
+	DescribeTable("Wait modes",
+		func(cfg configPgk.SafeDriverLoadConfig, expected []string, unexpected []string) {
+			opts := newOpts()
+			opts.NodeName = testNodeName
+			opts.PodName = "driver"
+			opts.PodNamespace = testConfigMapNamespace
+			cfg.Enable = true
+			perms := app.RequiredPermissions(k8sClient, opts, &configPgk.Config{SafeDriverLoad: cfg})
+			Expect(perms).To(ContainElements(expected))
+			for _, p := range unexpected {
+				Expect(perms).NotTo(ContainElement(p))
+			}
+		},
+		Entry("annotation", configPgk.SafeDriverLoadConfig{Annotation: testAnnotation},
+			[]string{watchNodes, patchNodes, getPod}, nil),
+		Entry("label gate", configPgk.SafeDriverLoadConfig{GateType: configPgk.GateTypeLabel, Label: testLabel,
+			Annotation: testAnnotation},
+			[]string{watchNodes, patchNodes, getPod}, nil),
+		Entry("label gate without annotation", configPgk.SafeDriverLoadConfig{GateType: configPgk.GateTypeLabel,
+			Label: testLabel},
+			[]string{watchNodes, patchNodes}, []string{getPod}),
+		Entry("condition", configPgk.SafeDriverLoadConfig{WaitMode: configPgk.WaitModeCondition,
+			Annotation: testAnnotation, Condition: configPgk.ConditionConfig{Release: "true"}},
+			[]string{watchNodes}, []string{patchNodes, getPod}),
+		Entry("nodeMaintenance", configPgk.SafeDriverLoadConfig{WaitMode: configPgk.WaitModeNodeMaintenance},
+			[]string{"create nodemaintenances.maintenance.nvidia.com in namespace default (safeDriverLoad)"},
+			[]string{watchNodes, patchNodes, getPod}),
+		Entry("configMap handshake object", configPgk.SafeDriverLoadConfig{Annotation: testAnnotation,
+			HandshakeObject: configPgk.HandshakeObjectConfigMap},
+			[]string{"watch configmaps in namespace default (safeDriverLoad)", getPod},
+			[]string{watchNodes, patchNodes}),
+	)
+})
@@ -0,0 +1,100 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/status"
+	"github.com/Mellanox/network-operator-init-container/pkg/tracing"
+)
+
+// phase of the init container
+type phase string
+
+const (
+	phaseLoadConfig            phase = "LoadConfig"
+	phaseCheckPermissions      phase = "CheckPermissions"
+	phaseWaitForNode           phase = "WaitForNode"
+	phaseAcquireNodeLock       phase = "AcquireNodeLock"
+	phaseDeviceInventory       phase = "DeviceInventory"
+	phasePreflight             phase = "Preflight"
+	phaseSRIOVSnapshot         phase = "SRIOVSnapshot"
+	phaseWaitForPreviousPod    phase = "WaitForPreviousPod"
+	phaseDrain                 phase = "Drain"
+	phaseSetAnnotation         phase = "SetAnnotation"
+	phaseWait                  phase = "WaitForAnnotationRemoval"
+	phaseWaitCondition         phase = "WaitForCondition"
+	phaseCreateNodeMaintenance phase = "CreateNodeMaintenance"
+	phaseWaitNodeMaintenance   phase = "WaitForNodeMaintenance"
+	phaseWaitForWorkloads      phase = "WaitForWorkloads"
+	phaseRelease               phase = "Release"
+	phaseDone                  phase = "Done"
+	phaseFailed                phase = "Failed"
+)
+
+// phaseReporter reports phases of the init container to the log and the status tracker,
+// every phase is recorded as a child span of the span from ctx
+type phaseReporter struct {
+	ctx     context.Context
+	logger  logr.Logger
+	tracker *status.Tracker
+	span    trace.Span
+}
+
+func newPhaseReporter(ctx context.Context, logger logr.Logger, tracker *status.Tracker) *phaseReporter {
+	return &phaseReporter{ctx: ctx, logger: logger, tracker: tracker}
+}
+
+// set starts the phase and ends the span of the previous phase
+func (r *phaseReporter) set(p phase) {
+	r.endSpan(nil)
+	r.logger.V(1).Info("phase changed", "phase", p)
+	r.tracker.SetPhase(string(p))
+	_, r.span = tracing.Tracer().Start(r.ctx, string(p))
+}
+
+// finish ends the span of the current phase and sets the final phase according to err
+func (r *phaseReporter) finish(err error) {
+	r.endSpan(err)
+	p := phaseDone
+	if err != nil {
+		r.tracker.SetError(err)
+		p = phaseFailed
+	}
+	r.logger.V(1).Info("phase changed", "phase", p)
+	r.tracker.SetPhase(string(p))
+}
+
+func (r *phaseReporter) endSpan(err error) {
+	if r.span == nil {
+		return
+	}
+	recordSpanError(r.span, err)
+	r.span.End()
+	r.span = nil
+}
+
+// recordSpanError marks the span as failed if err is not nil
+func recordSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,154 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// podWatcher waits until no pod on the Node matches the filter,
+// pods are watched with spec.nodeName field selector, matching pods are reported to the log and as Events
+type podWatcher struct {
+	logger   logr.Logger
+	recorder record.EventRecorder
+	node     *corev1.Node
+	// namespace to watch pods in, all namespaces if empty
+	namespace string
+	// label selector for pods, all pods if nil
+	selector labels.Selector
+	// returns true if the pod blocks the init container
+	match func(pod *corev1.Pod) bool
+	// reason and message for Events
+	eventReason  string
+	eventMessage string
+	// last reported blocking pods
+	reported string
+}
+
+const (
+	// initial and max delay before pods are listed again after the watch was closed
+	podRelistBackoff    = time.Second
+	podRelistMaxBackoff = 30 * time.Second
+)
+
+// wait blocks until no pod on the Node matches the filter or ctx is canceled
+func (w *podWatcher) wait(ctx context.Context, k8sClient client.WithWatch) error {
+	backoff := wait.Backoff{Duration: podRelistBackoff, Factor: 2, Jitter: 0.1,
+		Steps: math.MaxInt32, Cap: podRelistMaxBackoff}
+	for {
+		done, err := w.run(ctx, k8sClient)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		// watch was closed by the API server, list pods again after the delay to not overload the API server
+		delay := backoff.Step()
+		w.logger.V(1).Info("pod watch closed, list pods again", "delay", delay)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for pods canceled: %v", ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// run lists and watches pods on the Node, returns true if no pod matches the filter,
+// returns false if the watch was closed
+func (w *podWatcher) run(ctx context.Context, k8sClient client.WithWatch) (bool, error) {
+	listOpts := []client.ListOption{client.MatchingFields{"spec.nodeName": w.node.Name}}
+	if w.namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(w.namespace))
+	}
+	if w.selector != nil {
+		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: w.selector})
+	}
+	pods := &corev1.PodList{}
+	if err := k8sClient.List(ctx, pods, listOpts...); err != nil {
+		return false, err
+	}
+	blocking := map[string]bool{}
+	for i := range pods.Items {
+		if w.match(&pods.Items[i]) {
+			blocking[client.ObjectKeyFromObject(&pods.Items[i]).String()] = true
+		}
+	}
+	if len(blocking) == 0 {
+		return true, nil
+	}
+	w.report(blocking)
+
+	watcher, err := k8sClient.Watch(ctx, &corev1.PodList{}, append(listOpts,
+		&client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: pods.ResourceVersion}})...)
+	if err != nil {
+		return false, err
+	}
+	defer watcher.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("waiting for pods canceled: %v", ctx.Err())
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			pod, isPod := event.Object.(*corev1.Pod)
+			if !isPod {
+				// e.g. the resource version is too old, list pods again
+				return false, nil
+			}
+			key := client.ObjectKeyFromObject(pod).String()
+			if event.Type != watch.Deleted && w.match(pod) {
+				blocking[key] = true
+			} else {
+				delete(blocking, key)
+			}
+			if len(blocking) == 0 {
+				return true, nil
+			}
+			w.report(blocking)
+		}
+	}
+}
+
+// report logs blocking pods and records an Event for the Node if the list of the pods changed
+func (w *podWatcher) report(blocking map[string]bool) {
+	pods := make([]string, 0, len(blocking))
+	for p := range blocking {
+		pods = append(pods, p)
+	}
+	sort.Strings(pods)
+	msg := strings.Join(pods, ", ")
+	if msg == w.reported {
+		return
+	}
+	w.reported = msg
+	w.logger.Info(w.eventMessage, "pods", pods)
+	w.recorder.Eventf(w.node, corev1.EventTypeNormal, w.eventReason, "%s: %s", w.eventMessage, msg)
+}
@@ -0,0 +1,152 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/inventory"
+	"github.com/Mellanox/network-operator-init-container/pkg/preflight"
+)
+
+const (
+	preflightCheckSecureBoot    = "SecureBoot"
+	preflightCheckKernelHeaders = "KernelHeaders"
+	preflightCheckDiskSpace     = "DiskSpace"
+	preflightCheckFirmware      = "Firmware"
+
+	eventReasonPreflightPassed = "PreflightCheckPassed"
+	eventReasonPreflightFailed = "PreflightCheckFailed"
+)
+
+// runPreflight executes enabled preflight checks and reports results,
+// returns error if the driver can't be loaded on the host
+func runPreflight(ctx context.Context, logger logr.Logger, k8sClient client.Client, recorder record.EventRecorder,
+	opts *options.Options, cfg configPgk.PreflightConfig) error {
+	results := []preflight.Result{}
+
+	if cfg.SecureBoot.Enable {
+		status, err := preflight.CheckSecureBoot(opts.HostRoot, cfg.SecureBoot.DriverSigned)
+		msg := ""
+		if status != nil {
+			msg = fmt.Sprintf("secureBoot: %t, lockdown: %s", status.SecureBoot, status.Lockdown)
+		}
+		results = append(results, newPreflightResult(preflightCheckSecureBoot, msg, err))
+	}
+
+	if cfg.KernelHeaders.Enable {
+		release, err := preflight.KernelRelease()
+		if err == nil {
+			err = preflight.CheckKernelHeaders(opts.HostRoot, release)
+		}
+		results = append(results, newPreflightResult(preflightCheckKernelHeaders,
+			fmt.Sprintf("kernel headers for %s are available", release), err))
+	}
+
+	for _, ds := range cfg.DiskSpace {
+		//nolint:gosec
+		free, err := preflight.CheckDiskSpace(opts.HostRoot, ds.Path, uint64(ds.MinFree.Value()))
+		results = append(results, newPreflightResult(preflightCheckDiskSpace,
+			fmt.Sprintf("free space on %s is %d bytes", ds.Path, free), err))
+	}
+
+	if len(cfg.Firmware.MinVersions) > 0 {
+		devices, err := inventory.Discover(opts.HostRoot)
+		if err == nil {
+			err = preflight.CheckFirmware(devices, cfg.Firmware.MinVersions)
+		}
+		r := newPreflightResult(preflightCheckFirmware, "firmware satisfies minimal versions", err)
+		r.Warning = !r.Passed && cfg.Firmware.Policy == configPgk.FirmwarePolicyWarn
+		results = append(results, r)
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+
+	var preflightErr error
+	for _, r := range results {
+		if r.Passed {
+			logger.Info("preflight check passed", "check", r.Check, "message", r.Message)
+			continue
+		}
+		err := &preflight.Error{Reason: r.Reason, Message: r.Message}
+		if r.Warning {
+			logger.Info("WARNING: preflight check failed, ignore according to the policy",
+				"check", r.Check, "reason", r.Reason, "message", r.Message)
+			continue
+		}
+		logger.Error(err, "preflight check failed", "check", r.Check)
+		if preflightErr == nil {
+			preflightErr = err
+		}
+	}
+
+	if err := reportPreflightResults(ctx, k8sClient, recorder, opts.NodeName, cfg.ResultAnnotation, results); err != nil {
+		logger.Error(err, "failed to report results of preflight checks", "node", opts.NodeName)
+		if preflightErr == nil {
+			return err
+		}
+	}
+	return preflightErr
+}
+
+// newPreflightResult creates preflight.Result for the check, msg is used as a message if the check passed
+func newPreflightResult(check, msg string, err error) preflight.Result {
+	if err == nil {
+		return preflight.Result{Check: check, Passed: true, Message: msg}
+	}
+	preflightErr := &preflight.Error{}
+	if errors.As(err, &preflightErr) {
+		return preflight.Result{Check: check, Reason: preflightErr.Reason, Message: preflightErr.Message}
+	}
+	return preflight.Result{Check: check, Reason: "CheckError", Message: err.Error()}
+}
+
+// reportPreflightResults reports results of preflight checks as Events for the Node object,
+// results are also saved to the Node annotation if annotation is not empty
+func reportPreflightResults(ctx context.Context, k8sClient client.Client, recorder record.EventRecorder,
+	nodeName, annotation string, results []preflight.Result) error {
+	node := &corev1.Node{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Passed {
+			recorder.Eventf(node, corev1.EventTypeNormal, eventReasonPreflightPassed, "%s: %s", r.Check, r.Message)
+		} else {
+			recorder.Eventf(node, corev1.EventTypeWarning, eventReasonPreflightFailed, "%s: %s: %s",
+				r.Check, r.Reason, r.Message)
+		}
+	}
+	if annotation == "" {
+		return nil
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	return setNodeAnnotations(ctx, k8sClient, fieldManagerPreflight, nodeName, map[string]string{annotation: string(data)})
+}
@@ -0,0 +1,95 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+const eventReasonWaitingForPreviousPod = "WaitingForPreviousPod"
+
+// waitForPreviousPod waits until other driver pods on the Node are deleted,
+// the pods are selected with the label selector from the configuration or by the owner DaemonSet
+// of the init container pod
+func waitForPreviousPod(ctx context.Context, logger logr.Logger, k8sClient client.WithWatch,
+	recorder record.EventRecorder, opts *options.Options, cfg configPgk.WaitForPreviousPodConfig) error {
+	logger = logger.WithValues("node", opts.NodeName)
+	if opts.PodName == "" || opts.PodNamespace == "" {
+		err := fmt.Errorf("pod-name and pod-namespace parameters are required to wait for the previous driver pod")
+		logger.Error(err, "can't wait for the previous driver pod")
+		return err
+	}
+	if cfg.Timeout.Duration > 0 {
+		var cFunc context.CancelFunc
+		ctx, cFunc = context.WithTimeout(ctx, cfg.Timeout.Duration)
+		defer cFunc()
+	}
+	node := &corev1.Node{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: opts.NodeName}, node); err != nil {
+		logger.Error(err, "failed to read node object from the API")
+		return err
+	}
+	w := &podWatcher{
+		logger:       logger,
+		recorder:     recorder,
+		node:         node,
+		namespace:    opts.PodNamespace,
+		eventReason:  eventReasonWaitingForPreviousPod,
+		eventMessage: "wait for the previous driver pod to terminate",
+	}
+	if cfg.LabelSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(cfg.LabelSelector)
+		if err != nil {
+			logger.Error(err, "invalid label selector")
+			return err
+		}
+		w.selector = selector
+		w.match = func(pod *corev1.Pod) bool { return pod.Name != opts.PodName }
+	} else {
+		self := &corev1.Pod{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{
+			Namespace: opts.PodNamespace, Name: opts.PodName}, self); err != nil {
+			logger.Error(err, "failed to read pod object from the API", "pod", opts.PodName)
+			return err
+		}
+		owner := metav1.GetControllerOf(self)
+		if owner == nil || owner.Kind != "DaemonSet" {
+			err := fmt.Errorf("pod %s/%s is not owned by DaemonSet, labelSelector should be set",
+				opts.PodNamespace, opts.PodName)
+			logger.Error(err, "can't wait for the previous driver pod")
+			return err
+		}
+		w.match = func(pod *corev1.Pod) bool {
+			podOwner := metav1.GetControllerOf(pod)
+			return pod.Name != opts.PodName && podOwner != nil && podOwner.UID == owner.UID
+		}
+	}
+	if err := w.wait(ctx, k8sClient); err != nil {
+		logger.Error(err, "failed to wait for the previous driver pod")
+		return err
+	}
+	logger.Info("no previous driver pods on the node")
+	return nil
+}
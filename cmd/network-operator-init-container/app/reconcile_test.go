@@ -0,0 +1,75 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app"
+)
+
+var _ = Describe("Node reconciler", func() {
+	var getErr error
+	newReconciler := func() *app.NodeReconciler {
+		return &app.NodeReconciler{
+			ErrCh:              make(chan error, 1),
+			SafeLoadAnnotation: "some-annotation",
+			Client: fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey,
+					obj client.Object, opts ...client.GetOption) error {
+					return getErr
+				},
+			}).Build(),
+		}
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "node1"}}
+	nodes := schema.GroupResource{Resource: "nodes"}
+
+	DescribeTable("Transient errors are retried by the controller",
+		func(err error) {
+			getErr = err
+			r := newReconciler()
+			_, reconcileErr := r.Reconcile(context.Background(), req)
+			Expect(reconcileErr).To(MatchError(err))
+			Expect(r.ErrCh).To(BeEmpty())
+		},
+		Entry("service unavailable", apiErrors.NewServiceUnavailable("unavailable")),
+		Entry("timeout", apiErrors.NewServerTimeout(nodes, "get", 1)),
+		Entry("internal error", apiErrors.NewInternalError(context.DeadlineExceeded)),
+	)
+	It("Permanent error stops the wait", func() {
+		getErr = apiErrors.NewForbidden(nodes, "node1", context.Canceled)
+		r := newReconciler()
+		_, reconcileErr := r.Reconcile(context.Background(), req)
+		Expect(reconcileErr).To(HaveOccurred())
+		Expect(r.ErrCh).To(Receive(MatchError(getErr)))
+	})
+	It("Deleted Node stops the wait", func() {
+		getErr = apiErrors.NewNotFound(nodes, "node1")
+		r := newReconciler()
+		_, reconcileErr := r.Reconcile(context.Background(), req)
+		Expect(reconcileErr).NotTo(HaveOccurred())
+		Expect(r.ErrCh).To(Receive(MatchError(ContainSubstring("was deleted while waiting for the gate"))))
+	})
+})
@@ -0,0 +1,113 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+	cliflag "k8s.io/component-base/cli/flag"
+	"k8s.io/component-base/term"
+	"k8s.io/klog/v2"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/sriov"
+)
+
+// vfConfigurator reads and applies settings of the VFs, replaced in tests
+var vfConfigurator = sriov.NewNetlinkVFConfigurator()
+
+// newRestoreCommand creates a new command which restores SR-IOV configuration from the snapshot
+func newRestoreCommand(ctx context.Context) *cobra.Command {
+	opts := options.NewRestoreOptions()
+
+	cmd := &cobra.Command{
+		Use:          "restore",
+		Short:        "Restore SR-IOV configuration saved before the driver reload",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Validate(); err != nil {
+				return fmt.Errorf("invalid config: %w", err)
+			}
+			return RunRestore(logr.NewContext(ctx, klog.NewKlogr()), opts)
+		},
+		Args: cobra.NoArgs,
+	}
+
+	sharedFS := cliflag.NamedFlagSets{}
+	opts.AddNamedFlagSets(&sharedFS)
+
+	cmdFS := cmd.Flags()
+	for _, f := range sharedFS.FlagSets {
+		cmdFS.AddFlagSet(f)
+	}
+
+	cols, _, _ := term.TerminalSize(cmd.OutOrStdout())
+	cliflag.SetUsageAndHelpFunc(cmd, sharedFS, cols)
+
+	return cmd
+}
+
+// RunRestore applies SR-IOV configuration from the snapshot
+func RunRestore(ctx context.Context, opts *options.RestoreOptions) error {
+	logger := logr.FromContextOrDiscard(ctx)
+	state, err := sriov.LoadState(opts.HostRoot, opts.StateFile)
+	if err != nil {
+		logger.Error(err, "failed to load SR-IOV configuration snapshot", "path", opts.StateFile)
+		return err
+	}
+	logger.Info("restore SR-IOV configuration", "state", state)
+	if err := sriov.Restore(opts.HostRoot, state, vfConfigurator); err != nil {
+		logger.Error(err, "failed to restore SR-IOV configuration")
+		return err
+	}
+	logger.Info("SR-IOV configuration restored")
+	return nil
+}
+
+// runSRIOVSnapshot saves SR-IOV configuration of the host to the state file
+func runSRIOVSnapshot(logger logr.Logger, opts *options.Options, cfg configPgk.SRIOVConfig) error {
+	stateFile := cfg.StateFile
+	if stateFile == "" {
+		stateFile = sriov.DefaultStateFile
+	}
+	state, err := sriov.Snapshot(opts.HostRoot, vfConfigurator)
+	if err != nil {
+		logger.Error(err, "failed to read SR-IOV configuration")
+		return err
+	}
+	if len(state.PFs) == 0 {
+		// VFs can be already reset, e.g. the container is restarted after the driver reload,
+		// the existing snapshot is kept to not lose the configuration if it was taken in the same boot,
+		// otherwise VFs are removed intentionally and the snapshot is overwritten
+		if saved, err := sriov.LoadState(opts.HostRoot, stateFile); err == nil && len(saved.PFs) != 0 {
+			if saved.BootID != "" && saved.BootID == state.BootID {
+				logger.Info("no PFs with VFs enabled, keep existing SR-IOV configuration snapshot of the same boot",
+					"path", stateFile, "state", saved)
+				return nil
+			}
+			logger.Info("no PFs with VFs enabled, existing SR-IOV configuration snapshot is taken in another boot, "+
+				"overwrite it", "path", stateFile, "bootID", saved.BootID)
+		}
+	}
+	if err := sriov.SaveState(opts.HostRoot, stateFile, state); err != nil {
+		logger.Error(err, "failed to save SR-IOV configuration", "path", stateFile)
+		return err
+	}
+	logger.Info("SR-IOV configuration saved", "path", stateFile, "state", state)
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app"
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+	"github.com/Mellanox/network-operator-init-container/pkg/sriov"
+)
+
+// fakeVFConfigurator keeps settings of the VFs in memory
+type fakeVFConfigurator struct {
+	vfs map[string][]sriov.VFState
+}
+
+func (f *fakeVFConfigurator) VFs(netDev string) ([]sriov.VFState, error) {
+	return f.vfs[netDev], nil
+}
+
+func (f *fakeVFConfigurator) SetVF(netDev string, vf sriov.VFState) error {
+	for i := range f.vfs[netDev] {
+		if f.vfs[netDev][i].ID == vf.ID {
+			f.vfs[netDev][i] = vf
+			return nil
+		}
+	}
+	return fmt.Errorf("VF %d of %s not found", vf.ID, netDev)
+}
+
+// createPF creates fake sysfs entries for the PF, returns path to the PCI device
+func createPF(hostRoot, netDev, pciAddress, numVFs string) string {
+	devPath := filepath.Join(hostRoot, sriov.PCIDevicesPath, pciAddress)
+	ExpectWithOffset(1, os.MkdirAll(filepath.Join(devPath, "net", netDev), 0o755)).NotTo(HaveOccurred())
+	setNumVFs(devPath, numVFs)
+	netPath := filepath.Join(hostRoot, sriov.NetClassPath, netDev)
+	ExpectWithOffset(1, os.MkdirAll(netPath, 0o755)).NotTo(HaveOccurred())
+	ExpectWithOffset(1, os.Symlink(devPath, filepath.Join(netPath, "device"))).NotTo(HaveOccurred())
+	return devPath
+}
+
+func setNumVFs(devPath, numVFs string) {
+	ExpectWithOffset(1, os.WriteFile(filepath.Join(devPath, "sriov_numvfs"), []byte(numVFs+"\n"), 0o644)).
+		NotTo(HaveOccurred())
+}
+
+func setBootID(hostRoot, bootID string) {
+	path := filepath.Join(hostRoot, sriov.BootIDPath)
+	ExpectWithOffset(1, os.MkdirAll(filepath.Dir(path), 0o755)).NotTo(HaveOccurred())
+	ExpectWithOffset(1, os.WriteFile(path, []byte(bootID+"\n"), 0o644)).NotTo(HaveOccurred())
+}
+
+var _ = Describe("SR-IOV configuration", func() {
+	var (
+		testCtx  context.Context
+		hostRoot string
+		pf       string
+		vfs      *fakeVFConfigurator
+	)
+
+	BeforeEach(func() {
+		var cFunc context.CancelFunc
+		testCtx, cFunc = context.WithCancel(ctx)
+		DeferCleanup(cFunc)
+		hostRoot = GinkgoT().TempDir()
+		pf = createPF(hostRoot, "eth0", "0000:08:00.0", "2")
+		vfs = &fakeVFConfigurator{vfs: map[string][]sriov.VFState{"eth0": {
+			{ID: 0, MAC: "02:00:00:00:00:01", VLAN: 10, Trust: true},
+			{ID: 1, SpoofCheck: true},
+		}}}
+		DeferCleanup(app.SetVFConfigurator(vfs))
+		DeferCleanup(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name: testConfigMapName, Namespace: testConfigMapNamespace}}))).NotTo(HaveOccurred())
+		})
+	})
+
+	It("Snapshot", func() {
+		opts := newOpts()
+		opts.NodeName = testNodeName
+		opts.HostRoot = hostRoot
+		setBootID(hostRoot, "boot-1")
+		createConfig(configPgk.Config{SRIOV: configPgk.SRIOVConfig{Enable: true}})
+		Expect(app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)).NotTo(HaveOccurred())
+		state, err := sriov.LoadState(hostRoot, sriov.DefaultStateFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.BootID).To(Equal("boot-1"))
+		Expect(state.PFs).To(HaveLen(1))
+		Expect(state.PFs[0].NumVFs).To(Equal(2))
+		Expect(state.PFs[0].VFs).To(Equal(vfs.vfs["eth0"]))
+
+		// VFs are reset, e.g. the container is restarted after the driver reload
+		setNumVFs(pf, "0")
+		Expect(app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)).NotTo(HaveOccurred())
+		kept, err := sriov.LoadState(hostRoot, sriov.DefaultStateFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kept).To(Equal(state))
+	})
+	It("Snapshot - VFs removed in another boot", func() {
+		opts := newOpts()
+		opts.NodeName = testNodeName
+		opts.HostRoot = hostRoot
+		setBootID(hostRoot, "boot-1")
+		createConfig(configPgk.Config{SRIOV: configPgk.SRIOVConfig{Enable: true}})
+		Expect(app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)).NotTo(HaveOccurred())
+
+		// VFs are removed by the administrator after the reboot
+		setBootID(hostRoot, "boot-2")
+		setNumVFs(pf, "0")
+		Expect(app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)).NotTo(HaveOccurred())
+		state, err := sriov.LoadState(hostRoot, sriov.DefaultStateFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(Equal(&sriov.State{BootID: "boot-2", PFs: []sriov.PFState{}}))
+	})
+	It("Snapshot - boot ID unknown", func() {
+		opts := newOpts()
+		opts.NodeName = testNodeName
+		opts.HostRoot = hostRoot
+		createConfig(configPgk.Config{SRIOV: configPgk.SRIOVConfig{Enable: true}})
+		Expect(app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)).NotTo(HaveOccurred())
+
+		setNumVFs(pf, "0")
+		Expect(app.RunNetworkOperatorInitContainer(testCtx, cfg, opts)).NotTo(HaveOccurred())
+		state, err := sriov.LoadState(hostRoot, sriov.DefaultStateFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.PFs).To(BeEmpty())
+	})
+	It("Restore", func() {
+		Expect(sriov.SaveState(hostRoot, sriov.DefaultStateFile, &sriov.State{PFs: []sriov.PFState{{
+			PCIAddress: "0000:08:00.0", NetDev: "eth0", NumVFs: 4,
+			VFs: []sriov.VFState{{ID: 1, MAC: "02:00:00:00:00:02", VLAN: 20}},
+		}}})).NotTo(HaveOccurred())
+		opts := options.NewRestoreOptions()
+		opts.HostRoot = hostRoot
+		Expect(opts.Validate()).NotTo(HaveOccurred())
+		Expect(app.RunRestore(testCtx, opts)).NotTo(HaveOccurred())
+		data, err := os.ReadFile(filepath.Join(pf, "sriov_numvfs"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("4"))
+		Expect(vfs.vfs["eth0"][1]).To(Equal(sriov.VFState{ID: 1, MAC: "02:00:00:00:00:02", VLAN: 20}))
+	})
+	It("Restore - no state file", func() {
+		opts := options.NewRestoreOptions()
+		opts.HostRoot = hostRoot
+		Expect(app.RunRestore(testCtx, opts)).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,93 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/handshake"
+)
+
+// reasons why the existing annotation is reset, taken over or kept by the container
+const (
+	// the annotation can't be decoded
+	annotationReasonInvalidPayload = "InvalidPayload"
+	// the Node was rebooted after the annotation was set
+	annotationReasonNodeRebooted = "NodeRebooted"
+	// the pod which set the annotation doesn't exist anymore
+	annotationReasonOwnerNotFound = "OwnerPodNotFound"
+	// the pod which set the annotation was recreated with the same name
+	annotationReasonOwnerReplaced = "OwnerPodReplaced"
+	// the pod which set the annotation is being deleted, e.g. during a rolling update of the DaemonSet
+	annotationReasonOwnerTerminating = "OwnerPodTerminating"
+	// the pod which set the annotation has completed, it is in Succeeded or Failed phase
+	annotationReasonOwnerCompleted = "OwnerPodCompleted"
+	// the owner doesn't renew the heartbeat
+	annotationReasonHeartbeatExpired = "HeartbeatExpired"
+	// the annotation was set by this pod, e.g. the container was restarted
+	annotationReasonOwnedByThisPod = "OwnedByThisPod"
+	// the annotation has no information about the owner, e.g. set by an older version
+	annotationReasonNoOwnerInfo = "NoOwnerInfo"
+	// the pod which set the annotation still exists and its heartbeat has not expired,
+	// the annotation is not taken over
+	annotationReasonOwnerExists = "OwnerPodExists"
+)
+
+// inspectExistingAnnotation checks the annotation which already exists on the Node object,
+// returns the reason and true if the annotation is stale. The annotation which is not stale
+// can be taken over only if it is owned by this pod or has no owner information
+func inspectExistingAnnotation(ctx context.Context, k8sClient client.Client, node *corev1.Node,
+	value string, self *handshake.Owner) (string, bool, error) {
+	payload, err := handshake.Decode(value)
+	if err != nil {
+		return annotationReasonInvalidPayload, true, nil
+	}
+	if payload.BootID != "" && payload.BootID != node.Status.NodeInfo.BootID {
+		return annotationReasonNodeRebooted, true, nil
+	}
+	if payload.Owner == nil {
+		return annotationReasonNoOwnerInfo, false, nil
+	}
+	if payload.Owner.Is(self) {
+		return annotationReasonOwnedByThisPod, false, nil
+	}
+	pod := &corev1.Pod{}
+	err = k8sClient.Get(ctx, types.NamespacedName{
+		Namespace: payload.Owner.Namespace, Name: payload.Owner.Name}, pod)
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			return annotationReasonOwnerNotFound, true, nil
+		}
+		return "", false, err
+	}
+	if payload.Owner.UID != "" && payload.Owner.UID != string(pod.UID) {
+		return annotationReasonOwnerReplaced, true, nil
+	}
+	if pod.DeletionTimestamp != nil {
+		return annotationReasonOwnerTerminating, true, nil
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return annotationReasonOwnerCompleted, true, nil
+	}
+	if payload.HeartbeatExpired(time.Now()) {
+		return annotationReasonHeartbeatExpired, true, nil
+	}
+	return annotationReasonOwnerExists, false, nil
+}
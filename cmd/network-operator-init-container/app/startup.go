@@ -0,0 +1,62 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+)
+
+// kubeAPIConfig returns copy of the config with rate limits and content type from the options,
+// protobuf is used only for built-in types, custom resources are always sent as JSON
+func kubeAPIConfig(config *rest.Config, opts *options.Options) *rest.Config {
+	config = rest.CopyConfig(config)
+	if opts.KubeAPIQPS > 0 {
+		config.QPS = opts.KubeAPIQPS
+	}
+	if opts.KubeAPIBurst > 0 {
+		config.Burst = opts.KubeAPIBurst
+	}
+	if opts.KubeAPIProtobuf {
+		config.ContentType = runtime.ContentTypeProtobuf
+		config.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+	}
+	return config
+}
+
+// startupDelay sleeps for a random duration up to jitter, init containers of a DaemonSet
+// start on all nodes at the same time and the delay spreads their requests to the API server
+func startupDelay(ctx context.Context, logger logr.Logger, jitter time.Duration) error {
+	if jitter <= 0 {
+		return nil
+	}
+	delay := rand.N(jitter)
+	logger.Info("delay start to spread requests to the API server", "delay", delay.String())
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("startup delay canceled")
+	case <-timer.C:
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app"
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+)
+
+var _ = Describe("Startup", func() {
+	It("API client options", func() {
+		orig := &rest.Config{Host: "https://api", QPS: 20, Burst: 30}
+		opts := options.New()
+		opts.KubeAPIQPS = 5
+		opts.KubeAPIBurst = 10
+		opts.KubeAPIProtobuf = true
+		config := app.KubeAPIConfig(orig, opts)
+		Expect(config.Host).To(Equal("https://api"))
+		Expect(config.QPS).To(Equal(float32(5)))
+		Expect(config.Burst).To(Equal(10))
+		Expect(config.ContentType).To(Equal(runtime.ContentTypeProtobuf))
+		Expect(config.AcceptContentTypes).To(Equal(runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON))
+		// the original config is not changed
+		Expect(orig.QPS).To(Equal(float32(20)))
+		Expect(orig.ContentType).To(BeEmpty())
+	})
+	It("API client options - defaults", func() {
+		orig := &rest.Config{Host: "https://api", QPS: 20, Burst: 30}
+		opts := options.New()
+		opts.KubeAPIQPS = 0
+		opts.KubeAPIBurst = 0
+		opts.KubeAPIProtobuf = false
+		config := app.KubeAPIConfig(orig, opts)
+		Expect(config.QPS).To(Equal(float32(20)))
+		Expect(config.Burst).To(Equal(30))
+		Expect(config.ContentType).To(BeEmpty())
+	})
+	It("Startup delay - disabled", func() {
+		ctx, cFunc := context.WithCancel(context.Background())
+		cFunc()
+		Expect(app.StartupDelay(ctx, logr.Discard(), 0)).NotTo(HaveOccurred())
+	})
+	It("Startup delay - up to jitter", func() {
+		start := time.Now()
+		Expect(app.StartupDelay(context.Background(), logr.Discard(), 100*time.Millisecond)).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond+50*time.Millisecond))
+	})
+	It("Startup delay - waits until canceled", func() {
+		ctx, cFunc := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cFunc()
+		start := time.Now()
+		err := app.StartupDelay(ctx, logr.Discard(), time.Hour)
+		Expect(err).To(MatchError(ContainSubstring("startup delay canceled")))
+		Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+	})
+})
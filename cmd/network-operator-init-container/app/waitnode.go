@@ -0,0 +1,61 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+const waitForNodePollInterval = 5 * time.Second
+
+// waitForNode waits until the Node object exists, the init container can start before
+// the Node object of a new node is registered or while the Node object is recreated
+func waitForNode(ctx context.Context, logger logr.Logger, k8sClient client.Client, nodeName string,
+	cfg configPgk.WaitForNodeConfig) error {
+	logger = logger.WithValues("node", nodeName)
+	timeout := cfg.GetTimeout()
+	err := wait.PollUntilContextTimeout(ctx, waitForNodePollInterval, timeout, true,
+		func(ctx context.Context) (bool, error) {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: nodeName}, &corev1.Node{})
+			if apiErrors.IsNotFound(err) {
+				logger.Info("Node object not found, waiting")
+				return false, nil
+			}
+			if err != nil {
+				logger.Error(err, "failed to read node object from the API")
+				return false, err
+			}
+			return true, nil
+		})
+	if err != nil {
+		if wait.Interrupted(err) && ctx.Err() == nil {
+			err = fmt.Errorf("node object %s was not created within %s", nodeName, timeout)
+		}
+		logger.Error(err, "failed to wait for Node object")
+		return err
+	}
+	logger.Info("Node object found")
+	return nil
+}
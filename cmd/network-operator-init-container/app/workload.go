@@ -0,0 +1,97 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Mellanox/network-operator-init-container/cmd/network-operator-init-container/app/options"
+	configPgk "github.com/Mellanox/network-operator-init-container/pkg/config"
+)
+
+const (
+	hostRDMADevicesPath = "/dev/infiniband"
+
+	eventReasonWaitingForWorkloads = "WaitingForWorkloads"
+)
+
+// waitForWorkloads waits until no pod on the Node uses RDMA,
+// pods are watched with spec.nodeName field selector, blocking pods are reported to the log and as Events
+func waitForWorkloads(ctx context.Context, logger logr.Logger, k8sClient client.WithWatch,
+	recorder record.EventRecorder, opts *options.Options, cfg configPgk.WaitForWorkloadsConfig) error {
+	logger = logger.WithValues("node", opts.NodeName)
+	if cfg.Timeout.Duration > 0 {
+		var cFunc context.CancelFunc
+		ctx, cFunc = context.WithTimeout(ctx, cfg.Timeout.Duration)
+		defer cFunc()
+	}
+	node := &corev1.Node{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: opts.NodeName}, node); err != nil {
+		logger.Error(err, "failed to read node object from the API")
+		return err
+	}
+	self := types.NamespacedName{Namespace: opts.PodNamespace, Name: opts.PodName}
+	w := &podWatcher{
+		logger:       logger,
+		recorder:     recorder,
+		node:         node,
+		match:        func(pod *corev1.Pod) bool { return isRDMAWorkload(pod, cfg, self) },
+		eventReason:  eventReasonWaitingForWorkloads,
+		eventMessage: "wait for pods which use RDMA to leave the node",
+	}
+	if err := w.wait(ctx, k8sClient); err != nil {
+		logger.Error(err, "failed to wait for workloads")
+		return err
+	}
+	logger.Info("no pods use RDMA on the node")
+	return nil
+}
+
+// isRDMAWorkload returns true if the pod is running and requests matching resources
+// or mounts host RDMA devices, the pod of the init container is ignored.
+// Pending pods are ignored, they don't use RDMA yet and may wait for the driver, e.g. for the device plugin resources
+func isRDMAWorkload(pod *corev1.Pod, cfg configPgk.WaitForWorkloadsConfig, self types.NamespacedName) bool {
+	if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodUnknown {
+		return false
+	}
+	if pod.Namespace == self.Namespace && pod.Name == self.Name {
+		return false
+	}
+	if len(cfg.Resources) != 0 && podRequestsResources(pod, cfg.Resources) {
+		return true
+	}
+	return cfg.HostRDMADevices && podMountsHostPath(pod, hostRDMADevicesPath)
+}
+
+// podMountsHostPath returns true if the pod has hostPath volume for the path or its child
+func podMountsHostPath(pod *corev1.Pod, hostPath string) bool {
+	for _, v := range pod.Spec.Volumes {
+		if v.HostPath == nil {
+			continue
+		}
+		p := filepath.Clean(v.HostPath.Path)
+		if p == hostPath || strings.HasPrefix(p, hostPath+"/") {
+			return true
+		}
+	}
+	return false
+}
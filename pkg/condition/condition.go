@@ -0,0 +1,78 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package condition
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// NodeVariable is the name of the variable which contains the Node object in expressions
+const NodeVariable = "node"
+
+// Expression is a compiled CEL expression which returns bool
+type Expression struct {
+	source  string
+	program cel.Program
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Compile compiles CEL expression, the expression should return bool,
+// the Node object is available in the expression as the node variable
+func Compile(expr string) (*Expression, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(NodeVariable, cel.DynType),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("failed to compile expression %q: %w", expr, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression %q should return bool, returns %s", expr, ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create program for expression %q: %w", expr, err)
+	}
+	return &Expression{source: expr, program: program}, nil
+}
+
+// Evaluate evaluates the expression against the Node object
+func (e *Expression) Evaluate(node *corev1.Node) (bool, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(node)
+	if err != nil {
+		return false, err
+	}
+	out, _, err := e.program.Eval(map[string]interface{}{NodeVariable: obj})
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate expression %q: %w", e.source, err)
+	}
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q returned %s, expected bool", e.source, out.Type().TypeName())
+	}
+	return result, nil
+}
@@ -0,0 +1,67 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package condition_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/condition"
+)
+
+var _ = Describe("Condition", func() {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{
+			"feature.node.kubernetes.io/pci-15b3.present": "true"}},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}},
+	}
+	It("Label", func() {
+		expr, err := condition.Compile(
+			`node.metadata.labels["feature.node.kubernetes.io/pci-15b3.present"] == "true"`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expr.Evaluate(node)).To(BeTrue())
+	})
+	It("Condition", func() {
+		expr, err := condition.Compile(
+			`node.status.conditions.exists(c, c.type == "Ready" && c.status == "False")`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expr.Evaluate(node)).To(BeFalse())
+	})
+	It("Missing field", func() {
+		expr, err := condition.Compile(`has(node.metadata.annotations) && "foo" in node.metadata.annotations`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expr.Evaluate(node)).To(BeFalse())
+	})
+	It("Evaluation error", func() {
+		expr, err := condition.Compile(`node.metadata.annotations["foo"] == "bar"`)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = expr.Evaluate(node)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Invalid expression", func() {
+		_, err := condition.Compile(`node.metadata.labels[`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Not bool", func() {
+		_, err := condition.Compile(`"foo"`)
+		Expect(err).To(HaveOccurred())
+		expr, err := condition.Compile(`node.metadata.name`)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = expr.Evaluate(node)
+		Expect(err).To(HaveOccurred())
+	})
+})
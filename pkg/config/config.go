@@ -15,8 +15,19 @@ package config
 
 import (
 	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/condition"
+	"github.com/Mellanox/network-operator-init-container/pkg/preflight"
 )
 
 // Load parse configuration from the provided string
@@ -35,20 +46,586 @@ func Load(config string) (*Config, error) {
 type Config struct {
 	// configuration options for safeDriverLoading feature
 	SafeDriverLoad SafeDriverLoadConfig `json:"safeDriverLoad"`
+	// configuration options for preflight checks
+	Preflight PreflightConfig `json:"preflight"`
+	// configuration options for device inventory
+	DeviceInventory DeviceInventoryConfig `json:"deviceInventory"`
+	// configuration options for SR-IOV configuration snapshot
+	SRIOV SRIOVConfig `json:"sriov"`
+	// configuration options for cordon and drain of the Node
+	Drain DrainConfig `json:"drain"`
+	// configuration options for waiting for RDMA workloads to leave the Node
+	WaitForWorkloads WaitForWorkloadsConfig `json:"waitForWorkloads"`
+	// configuration options for waiting for the previous driver pod on the Node
+	WaitForPreviousPod WaitForPreviousPodConfig `json:"waitForPreviousPod"`
+	// configuration options for the per-node lock
+	NodeLock NodeLockConfig `json:"nodeLock"`
+	// configuration options for waiting for the Node object
+	WaitForNode WaitForNodeConfig `json:"waitForNode"`
 }
 
 // SafeDriverLoadConfig contains configuration options for safeDriverLoading feature
 type SafeDriverLoadConfig struct {
 	// enable safeDriverLoading feature
 	Enable bool `json:"enable"`
-	// annotation to use for safeDriverLoading feature
+	// annotation to use for safeDriverLoading feature,
+	// in the label gate type the annotation is optional and contains details of the wait
 	Annotation string `json:"annotation"`
+	// type of the gate, annotation or label, default is annotation
+	GateType GateType `json:"gateType,omitempty"`
+	// label to use as the gate in the label gate type, the value of the label is the compact state,
+	// e.g. waiting, operators can find waiting nodes with a label selector
+	Label string `json:"label,omitempty"`
+	// interval at which the init container renews the heartbeat timestamp in the annotation payload
+	// while it waits for the annotation removal, e.g. 30s, heartbeat is disabled if not set.
+	// Together with the heartbeat, the payload contains heartbeatTimeoutSeconds,
+	// the waiter should be considered dead by the operator if the heartbeat is older than the timeout,
+	// e.g. the pod was deleted without cleanup, and the annotation can be removed.
+	HeartbeatInterval metav1.Duration `json:"heartbeatInterval,omitempty"`
+	// heartbeat expiry timeout, default is 3 * heartbeatInterval
+	HeartbeatTimeout metav1.Duration `json:"heartbeatTimeout,omitempty"`
+	// additional annotations which are set together with the annotation, the init container waits
+	// until the annotation and all additional annotations and labels are removed,
+	// e.g. to wait for several independent controllers
+	AdditionalAnnotations []string `json:"additionalAnnotations,omitempty"`
+	// additional labels which are set together with the annotation
+	AdditionalLabels []string `json:"additionalLabels,omitempty"`
+	// what the init container waits for before it exits, annotation or condition, default is annotation
+	WaitMode WaitMode `json:"waitMode,omitempty"`
+	// condition to wait for in the condition wait mode
+	Condition ConditionConfig `json:"condition,omitempty"`
+	// NodeMaintenance object to create in the nodeMaintenance wait mode
+	NodeMaintenance NodeMaintenanceConfig `json:"nodeMaintenance,omitempty"`
+	// object which holds the gates in the annotation wait mode, node or configMap, default is node
+	HandshakeObject HandshakeObject `json:"handshakeObject,omitempty"`
+	// namespace for the ConfigMap in the configMap handshake object mode,
+	// default is the namespace of the ConfigMap with the configuration
+	HandshakeNamespace string `json:"handshakeNamespace,omitempty"`
+}
+
+// GateType defines which metadata of the Node object is used as the gate
+type GateType string
+
+const (
+	// GateTypeAnnotation uses the annotation as the gate
+	GateTypeAnnotation GateType = "annotation"
+	// GateTypeLabel uses the label as the gate and the annotation for details
+	GateTypeLabel GateType = "label"
+)
+
+// GetGateType returns the gate type, returns GateTypeAnnotation if not set
+func (c *SafeDriverLoadConfig) GetGateType() GateType {
+	if c.GateType == "" {
+		return GateTypeAnnotation
+	}
+	return c.GateType
+}
+
+// HandshakeObject defines which object holds the gates
+type HandshakeObject string
+
+const (
+	// HandshakeObjectNode sets the gates on the Node object
+	HandshakeObjectNode HandshakeObject = "node"
+	// HandshakeObjectConfigMap sets the gates on the per-node ConfigMap in the operator namespace,
+	// the init container needs only read access to the Node object in this mode
+	HandshakeObjectConfigMap HandshakeObject = "configMap"
+)
+
+// GetHandshakeObject returns the handshake object, returns HandshakeObjectNode if not set
+func (c *SafeDriverLoadConfig) GetHandshakeObject() HandshakeObject {
+	if c.HandshakeObject == "" {
+		return HandshakeObjectNode
+	}
+	return c.HandshakeObject
+}
+
+// WaitMode defines what the init container waits for before it exits
+type WaitMode string
+
+const (
+	// WaitModeAnnotation sets the annotation and waits for the annotation removal
+	WaitModeAnnotation WaitMode = "annotation"
+	// WaitModeCondition waits for the release expression to become true, the annotation is not set
+	WaitModeCondition WaitMode = "condition"
+	// WaitModeNodeMaintenance creates NodeMaintenance object for the Node and waits for its Ready condition,
+	// the annotation is not set
+	WaitModeNodeMaintenance WaitMode = "nodeMaintenance"
+)
+
+// default values for NodeMaintenanceConfig
+const (
+	DefaultNodeMaintenanceGroup       = "maintenance.nvidia.com"
+	DefaultNodeMaintenanceVersion     = "v1alpha1"
+	DefaultNodeMaintenanceKind        = "NodeMaintenance"
+	DefaultNodeMaintenanceNamespace   = "default"
+	DefaultNodeMaintenanceRequestorID = "network-operator-init-container"
+)
+
+// NodeMaintenanceConfig contains configuration options for nodeMaintenance wait mode
+type NodeMaintenanceConfig struct {
+	// API group of the NodeMaintenance custom resource, default is maintenance.nvidia.com
+	Group string `json:"group,omitempty"`
+	// API version of the NodeMaintenance custom resource, default is v1alpha1
+	Version string `json:"version,omitempty"`
+	// kind of the NodeMaintenance custom resource, default is NodeMaintenance
+	Kind string `json:"kind,omitempty"`
+	// namespace in which the NodeMaintenance object is created, default is default
+	Namespace string `json:"namespace,omitempty"`
+	// value for spec.requestorID of the NodeMaintenance object, default is network-operator-init-container
+	RequestorID string `json:"requestorID,omitempty"`
+	// additional fields for the spec of the NodeMaintenance object, e.g. drainSpec,
+	// spec.nodeName and spec.requestorID are always set by the init container
+	Spec map[string]interface{} `json:"spec,omitempty"`
+}
+
+// GetGroupVersionKind returns GroupVersionKind of the NodeMaintenance custom resource
+func (c *NodeMaintenanceConfig) GetGroupVersionKind() schema.GroupVersionKind {
+	gvk := schema.GroupVersionKind{Group: c.Group, Version: c.Version, Kind: c.Kind}
+	if gvk.Group == "" {
+		gvk.Group = DefaultNodeMaintenanceGroup
+	}
+	if gvk.Version == "" {
+		gvk.Version = DefaultNodeMaintenanceVersion
+	}
+	if gvk.Kind == "" {
+		gvk.Kind = DefaultNodeMaintenanceKind
+	}
+	return gvk
+}
+
+// GetNamespace returns namespace for the NodeMaintenance object
+func (c *NodeMaintenanceConfig) GetNamespace() string {
+	if c.Namespace == "" {
+		return DefaultNodeMaintenanceNamespace
+	}
+	return c.Namespace
+}
+
+// GetRequestorID returns requestorID for the NodeMaintenance object
+func (c *NodeMaintenanceConfig) GetRequestorID() string {
+	if c.RequestorID == "" {
+		return DefaultNodeMaintenanceRequestorID
+	}
+	return c.RequestorID
+}
+
+// ConditionConfig contains CEL expressions which are evaluated against the Node object,
+// the Node object is available in the expressions as the node variable
+type ConditionConfig struct {
+	// the init container exits with success when the expression becomes true,
+	// e.g. node.metadata.labels["feature.node.kubernetes.io/pci-15b3.present"] == "true"
+	Release string `json:"release,omitempty"`
+	// the init container fails when the expression becomes true, optional
+	Failure string `json:"failure,omitempty"`
+}
+
+// GetWaitMode returns the wait mode, returns WaitModeAnnotation if not set
+func (c *SafeDriverLoadConfig) GetWaitMode() WaitMode {
+	if c.WaitMode == "" {
+		return WaitModeAnnotation
+	}
+	return c.WaitMode
+}
+
+// GetHeartbeatTimeout returns heartbeat expiry timeout, returns 0 if heartbeat is disabled
+func (c *SafeDriverLoadConfig) GetHeartbeatTimeout() time.Duration {
+	if c.HeartbeatInterval.Duration == 0 {
+		return 0
+	}
+	if c.HeartbeatTimeout.Duration == 0 {
+		return 3 * c.HeartbeatInterval.Duration
+	}
+	return c.HeartbeatTimeout.Duration
+}
+
+// PreflightConfig contains configuration options for preflight checks,
+// the checks are executed before the Node is annotated
+type PreflightConfig struct {
+	// configuration options for Secure Boot and kernel lockdown check
+	SecureBoot SecureBootConfig `json:"secureBoot"`
+	// configuration options for kernel headers check
+	KernelHeaders KernelHeadersConfig `json:"kernelHeaders"`
+	// configuration options for free disk space checks
+	DiskSpace []DiskSpaceConfig `json:"diskSpace,omitempty"`
+	// configuration options for firmware version check
+	Firmware FirmwareConfig `json:"firmware"`
+	// annotation to use to report results of preflight checks on the Node object,
+	// results are reported only with Events if not set
+	ResultAnnotation string `json:"resultAnnotation,omitempty"`
+}
+
+// SecureBootConfig contains configuration options for Secure Boot and kernel lockdown check
+type SecureBootConfig struct {
+	// enable Secure Boot and kernel lockdown check
+	Enable bool `json:"enable"`
+	// the driver is signed and can be loaded by the kernel which enforces module signatures
+	DriverSigned bool `json:"driverSigned"`
+}
+
+// KernelHeadersConfig contains configuration options for kernel headers check
+type KernelHeadersConfig struct {
+	// enable check for /lib/modules/$(uname -r)/build directory on the host
+	Enable bool `json:"enable"`
+}
+
+// DiskSpaceConfig contains configuration options for free disk space check
+type DiskSpaceConfig struct {
+	// path on the host to check
+	Path string `json:"path"`
+	// minimal required free space, e.g. 2Gi
+	MinFree resource.Quantity `json:"minFree"`
+}
+
+// DeviceInventoryConfig contains configuration options for device inventory
+type DeviceInventoryConfig struct {
+	// enable discovery of NVIDIA/Mellanox network devices on the host,
+	// the container exits without setting safeDriverLoad annotation if no devices found
+	Enable bool `json:"enable"`
+	// annotation to use to publish the inventory on the Node object in JSON format,
+	// the inventory is not published if not set
+	Annotation string `json:"annotation,omitempty"`
+}
+
+// FirmwarePolicy defines how the container reacts on outdated firmware
+type FirmwarePolicy string
+
+const (
+	// FirmwarePolicyFail fails the container if firmware is outdated
+	FirmwarePolicyFail FirmwarePolicy = "fail"
+	// FirmwarePolicyWarn reports outdated firmware, but allows the driver to load
+	FirmwarePolicyWarn FirmwarePolicy = "warn"
+)
+
+// FirmwareConfig contains configuration options for firmware version check
+type FirmwareConfig struct {
+	// minimal firmware versions, the key is PCI device ID, e.g. "101d", the value is the version, e.g. "22.36.1010"
+	MinVersions map[string]string `json:"minVersions,omitempty"`
+	// policy to apply if firmware is outdated or unknown, fail or warn, default is fail
+	Policy FirmwarePolicy `json:"policy,omitempty"`
+}
+
+// normalizeMinVersions validates minVersions and converts device IDs to the format
+// of the device inventory, lower case without 0x prefix, e.g. 0x101D is converted to 101d
+func (c *FirmwareConfig) normalizeMinVersions() error {
+	if len(c.MinVersions) == 0 {
+		return nil
+	}
+	normalized := make(map[string]string, len(c.MinVersions))
+	for id, version := range c.MinVersions {
+		if _, err := preflight.ParseFirmwareVersion(version); err != nil {
+			return fmt.Errorf(".preflight.firmware.minVersions[%s] is invalid: %v", id, err)
+		}
+		deviceID := strings.TrimPrefix(strings.ToLower(id), "0x")
+		if _, err := strconv.ParseUint(deviceID, 16, 16); err != nil {
+			return fmt.Errorf(".preflight.firmware.minVersions[%s]: device ID should be hexadecimal PCI device ID", id)
+		}
+		if _, exist := normalized[deviceID]; exist {
+			return fmt.Errorf(".preflight.firmware.minVersions[%s]: duplicate device ID %s", id, deviceID)
+		}
+		normalized[deviceID] = version
+	}
+	c.MinVersions = normalized
+	return nil
+}
+
+// SRIOVConfig contains configuration options for SR-IOV configuration snapshot
+type SRIOVConfig struct {
+	// save SR-IOV configuration of the host before the Node is annotated,
+	// the configuration can be restored with the restore command after the driver is loaded
+	Enable bool `json:"enable"`
+	// path to the state file on the host, default is /run/network-operator-init-container/sriov-state.json
+	StateFile string `json:"stateFile,omitempty"`
+}
+
+// DefaultDrainTimeout is the default timeout for the drain of the Node
+const DefaultDrainTimeout = 5 * time.Minute
+
+// DrainConfig contains configuration options for cordon and drain of the Node
+type DrainConfig struct {
+	// cordon the Node and evict pods before the Node is annotated,
+	// the Node is uncordoned when the init container exits with success.
+	// Pods which belong to DaemonSets and mirror pods are not evicted
+	Enable bool `json:"enable"`
+	// patterns of resource names, e.g. nvidia.com/* or rdma/*, only pods which request
+	// matching resources are evicted, all pods are evicted if not set
+	PodResources []string `json:"podResources,omitempty"`
+	// timeout for the drain, default is 5m
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// evict pods which are not managed by a controller, these pods are not recreated after the eviction.
+	// The drain fails if such pods should be evicted and the option is not set
+	Force bool `json:"force,omitempty"`
+}
+
+// GetTimeout returns timeout for the drain
+func (c *DrainConfig) GetTimeout() time.Duration {
+	if c.Timeout.Duration == 0 {
+		return DefaultDrainTimeout
+	}
+	return c.Timeout.Duration
+}
+
+// WaitForWorkloadsConfig contains configuration options for waiting for RDMA workloads,
+// the init container waits before it exits with success until no pod on the Node uses RDMA
+type WaitForWorkloadsConfig struct {
+	// enable waiting for workloads
+	Enable bool `json:"enable"`
+	// patterns of extended resource names, e.g. rdma/hca_shared_devices_a or nvidia.com/*,
+	// pods which request or limit matching resources block the init container
+	Resources []string `json:"resources,omitempty"`
+	// pods which mount host RDMA devices (/dev/infiniband) block the init container
+	HostRDMADevices bool `json:"hostRDMADevices,omitempty"`
+	// timeout for waiting, the init container fails if the timeout expires, wait forever if not set
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// WaitForPreviousPodConfig contains configuration options for waiting for the previous driver pod,
+// e.g. the pod of the previous revision of the DaemonSet which is still terminating
+type WaitForPreviousPodConfig struct {
+	// enable waiting for the previous driver pod, the init container waits until other pods on the Node
+	// with the same owner DaemonSet are deleted, this requires --pod-name and --pod-namespace
+	Enable bool `json:"enable"`
+	// if set, the init container waits for other pods on the Node which match the selector
+	// in the namespace of the init container instead of pods with the same owner DaemonSet
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	// timeout for waiting, the init container fails if the timeout expires, wait forever if not set
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// NodeLockPolicy defines what the init container does if the node lock is held by another instance
+type NodeLockPolicy string
+
+const (
+	// NodeLockPolicyWait waits until the lock is released
+	NodeLockPolicyWait NodeLockPolicy = "wait"
+	// NodeLockPolicyFail fails the init container
+	NodeLockPolicyFail NodeLockPolicy = "fail"
+)
+
+// DefaultNodeLockLeaseDuration is the default duration of the node lock Lease
+const DefaultNodeLockLeaseDuration = 15 * time.Second
+
+// NodeLockConfig contains configuration options for the per-node lock,
+// the lock is a Lease named after the Node, only one instance of the init container on the Node
+// can hold the lock, the lock is acquired before the Node is changed and released when the init container exits
+type NodeLockConfig struct {
+	// enable the per-node lock
+	Enable bool `json:"enable"`
+	// namespace for the Lease, default is the namespace of the ConfigMap with the configuration
+	Namespace string `json:"namespace,omitempty"`
+	// duration of the Lease, default is 15s
+	LeaseDuration metav1.Duration `json:"leaseDuration,omitempty"`
+	// what to do if the lock is held by another instance, wait or fail, default is wait
+	Policy NodeLockPolicy `json:"policy,omitempty"`
+}
+
+// GetLeaseDuration returns duration of the Lease
+func (c *NodeLockConfig) GetLeaseDuration() time.Duration {
+	if c.LeaseDuration.Duration == 0 {
+		return DefaultNodeLockLeaseDuration
+	}
+	return c.LeaseDuration.Duration
+}
+
+// DefaultWaitForNodeTimeout is the default timeout for waiting for the Node object
+const DefaultWaitForNodeTimeout = 5 * time.Minute
+
+// WaitForNodeConfig contains configuration options for waiting for the Node object,
+// e.g. the init container can start before the Node object of a new node is registered
+type WaitForNodeConfig struct {
+	// wait until the Node object is created instead of failing if it doesn't exist
+	Enable bool `json:"enable"`
+	// timeout for waiting, the init container fails if the timeout expires, default is 5m
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// GetTimeout returns timeout for waiting for the Node object
+func (c *WaitForNodeConfig) GetTimeout() time.Duration {
+	if c.Timeout.Duration == 0 {
+		return DefaultWaitForNodeTimeout
+	}
+	return c.Timeout.Duration
 }
 
 // Validate checks the configuration
 func (c *Config) Validate() error {
-	if c.SafeDriverLoad.Enable && c.SafeDriverLoad.Annotation == "" {
-		return fmt.Errorf(".safeDriverLoad.annotation is required if safeDriverLoad feature is enabled")
+	switch c.SafeDriverLoad.GetWaitMode() {
+	case WaitModeAnnotation:
+		if err := c.validateGate(); err != nil {
+			return err
+		}
+		if err := c.validateAdditionalGates(); err != nil {
+			return err
+		}
+	case WaitModeCondition:
+		if len(c.SafeDriverLoad.AdditionalAnnotations) != 0 || len(c.SafeDriverLoad.AdditionalLabels) != 0 {
+			return fmt.Errorf(".safeDriverLoad.additionalAnnotations and .safeDriverLoad.additionalLabels "+
+				"are not supported in %s wait mode", WaitModeCondition)
+		}
+		if err := c.validateCondition(); err != nil {
+			return err
+		}
+	case WaitModeNodeMaintenance:
+		if len(c.SafeDriverLoad.AdditionalAnnotations) != 0 || len(c.SafeDriverLoad.AdditionalLabels) != 0 {
+			return fmt.Errorf(".safeDriverLoad.additionalAnnotations and .safeDriverLoad.additionalLabels "+
+				"are not supported in %s wait mode", WaitModeNodeMaintenance)
+		}
+		if errs := validation.IsDNS1123Subdomain(c.SafeDriverLoad.NodeMaintenance.GetNamespace()); len(errs) != 0 {
+			return fmt.Errorf(".safeDriverLoad.nodeMaintenance.namespace is invalid: %s", strings.Join(errs, ", "))
+		}
+	default:
+		return fmt.Errorf(".safeDriverLoad.waitMode should be %s, %s or %s",
+			WaitModeAnnotation, WaitModeCondition, WaitModeNodeMaintenance)
+	}
+	switch c.SafeDriverLoad.GetHandshakeObject() {
+	case HandshakeObjectNode:
+	case HandshakeObjectConfigMap:
+		if c.SafeDriverLoad.GetWaitMode() != WaitModeAnnotation {
+			return fmt.Errorf(".safeDriverLoad.handshakeObject %s is supported only in %s wait mode",
+				HandshakeObjectConfigMap, WaitModeAnnotation)
+		}
+		if ns := c.SafeDriverLoad.HandshakeNamespace; ns != "" {
+			if errs := validation.IsDNS1123Label(ns); len(errs) != 0 {
+				return fmt.Errorf(".safeDriverLoad.handshakeNamespace is invalid: %s", strings.Join(errs, ", "))
+			}
+		}
+	default:
+		return fmt.Errorf(".safeDriverLoad.handshakeObject should be %s or %s",
+			HandshakeObjectNode, HandshakeObjectConfigMap)
+	}
+	if c.SafeDriverLoad.HeartbeatInterval.Duration < 0 || c.SafeDriverLoad.HeartbeatTimeout.Duration < 0 {
+		return fmt.Errorf(".safeDriverLoad.heartbeatInterval and .safeDriverLoad.heartbeatTimeout can't be negative")
+	}
+	if c.SafeDriverLoad.HeartbeatTimeout.Duration != 0 &&
+		c.SafeDriverLoad.HeartbeatTimeout.Duration <= c.SafeDriverLoad.HeartbeatInterval.Duration {
+		return fmt.Errorf(".safeDriverLoad.heartbeatTimeout should be greater than .safeDriverLoad.heartbeatInterval")
+	}
+	// heartbeatTimeoutSeconds in the annotation payload is in whole seconds and 0 means that the heartbeat never expires
+	if t := c.SafeDriverLoad.GetHeartbeatTimeout(); t != 0 && t < time.Second {
+		return fmt.Errorf(".safeDriverLoad.heartbeatTimeout should be at least 1s")
+	}
+	for i, ds := range c.Preflight.DiskSpace {
+		if ds.Path == "" {
+			return fmt.Errorf(".preflight.diskSpace[%d].path is required", i)
+		}
+		if ds.MinFree.Sign() <= 0 {
+			return fmt.Errorf(".preflight.diskSpace[%d].minFree should be positive", i)
+		}
+	}
+	switch c.Preflight.Firmware.Policy {
+	case "", FirmwarePolicyFail, FirmwarePolicyWarn:
+	default:
+		return fmt.Errorf(".preflight.firmware.policy should be %s or %s",
+			FirmwarePolicyFail, FirmwarePolicyWarn)
+	}
+	if err := c.Preflight.Firmware.normalizeMinVersions(); err != nil {
+		return err
+	}
+	for i, pattern := range c.Drain.PodResources {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf(".drain.podResources[%d] is invalid: %v", i, err)
+		}
+	}
+	if c.Drain.Timeout.Duration < 0 {
+		return fmt.Errorf(".drain.timeout can't be negative")
+	}
+	if c.WaitForWorkloads.Enable && len(c.WaitForWorkloads.Resources) == 0 && !c.WaitForWorkloads.HostRDMADevices {
+		return fmt.Errorf(".waitForWorkloads.resources or .waitForWorkloads.hostRDMADevices is required " +
+			"if waitForWorkloads is enabled")
+	}
+	for i, pattern := range c.WaitForWorkloads.Resources {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf(".waitForWorkloads.resources[%d] is invalid: %v", i, err)
+		}
+	}
+	if c.WaitForWorkloads.Timeout.Duration < 0 {
+		return fmt.Errorf(".waitForWorkloads.timeout can't be negative")
+	}
+	if c.WaitForPreviousPod.LabelSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(c.WaitForPreviousPod.LabelSelector); err != nil {
+			return fmt.Errorf(".waitForPreviousPod.labelSelector is invalid: %v", err)
+		}
+	}
+	if c.WaitForPreviousPod.Timeout.Duration < 0 {
+		return fmt.Errorf(".waitForPreviousPod.timeout can't be negative")
+	}
+	if c.NodeLock.LeaseDuration.Duration != 0 && c.NodeLock.LeaseDuration.Duration < time.Second {
+		return fmt.Errorf(".nodeLock.leaseDuration should be at least 1s")
+	}
+	switch c.NodeLock.Policy {
+	case "", NodeLockPolicyWait, NodeLockPolicyFail:
+	default:
+		return fmt.Errorf(".nodeLock.policy should be %s or %s", NodeLockPolicyWait, NodeLockPolicyFail)
+	}
+	if c.WaitForNode.Timeout.Duration < 0 {
+		return fmt.Errorf(".waitForNode.timeout can't be negative")
+	}
+	return nil
+}
+
+// validateCondition checks expressions of the condition wait mode, they are used only if safeDriverLoad is enabled
+func (c *Config) validateCondition() error {
+	if !c.SafeDriverLoad.Enable {
+		return nil
+	}
+	if c.SafeDriverLoad.Condition.Release == "" {
+		return fmt.Errorf(".safeDriverLoad.condition.release is required in %s wait mode", WaitModeCondition)
+	}
+	if _, err := condition.Compile(c.SafeDriverLoad.Condition.Release); err != nil {
+		return fmt.Errorf(".safeDriverLoad.condition.release is invalid: %v", err)
+	}
+	if c.SafeDriverLoad.Condition.Failure != "" {
+		if _, err := condition.Compile(c.SafeDriverLoad.Condition.Failure); err != nil {
+			return fmt.Errorf(".safeDriverLoad.condition.failure is invalid: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateGate checks the gate configuration
+func (c *Config) validateGate() error {
+	switch c.SafeDriverLoad.GetGateType() {
+	case GateTypeAnnotation:
+		if c.SafeDriverLoad.Enable && c.SafeDriverLoad.Annotation == "" {
+			return fmt.Errorf(".safeDriverLoad.annotation is required if safeDriverLoad feature is enabled")
+		}
+	case GateTypeLabel:
+		if c.SafeDriverLoad.Enable && c.SafeDriverLoad.Label == "" {
+			return fmt.Errorf(".safeDriverLoad.label is required in %s gate type", GateTypeLabel)
+		}
+		if errs := validation.IsQualifiedName(c.SafeDriverLoad.Label); c.SafeDriverLoad.Label != "" && len(errs) != 0 {
+			return fmt.Errorf(".safeDriverLoad.label is invalid: %s", strings.Join(errs, ", "))
+		}
+	default:
+		return fmt.Errorf(".safeDriverLoad.gateType should be %s or %s", GateTypeAnnotation, GateTypeLabel)
+	}
+	return nil
+}
+
+// validateAdditionalGates checks names of additional annotations and labels
+func (c *Config) validateAdditionalGates() error {
+	seen := map[string]bool{c.SafeDriverLoad.Annotation: true}
+	for i, a := range c.SafeDriverLoad.AdditionalAnnotations {
+		if errs := validation.IsQualifiedName(a); len(errs) != 0 {
+			return fmt.Errorf(".safeDriverLoad.additionalAnnotations[%d] is invalid: %s", i, strings.Join(errs, ", "))
+		}
+		if seen[a] {
+			return fmt.Errorf(".safeDriverLoad.additionalAnnotations[%d] is duplicated", i)
+		}
+		seen[a] = true
+	}
+	seen = map[string]bool{}
+	if c.SafeDriverLoad.GetGateType() == GateTypeLabel {
+		seen[c.SafeDriverLoad.Label] = true
+	}
+	for i, l := range c.SafeDriverLoad.AdditionalLabels {
+		if errs := validation.IsQualifiedName(l); len(errs) != 0 {
+			return fmt.Errorf(".safeDriverLoad.additionalLabels[%d] is invalid: %s", i, strings.Join(errs, ", "))
+		}
+		if seen[l] {
+			return fmt.Errorf(".safeDriverLoad.additionalLabels[%d] is duplicated", i)
+		}
+		seen[l] = true
 	}
 	return nil
 }
@@ -15,6 +15,7 @@ package config_test
 
 import (
 	"encoding/json"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -55,4 +56,203 @@ var _ = Describe("Config test", func() {
 		}}))
 		Expect(err).To(HaveOccurred())
 	})
+	It("Valid - preflight disk space", func() {
+		cfg, err := configPgk.Load(`{"preflight": {"diskSpace": [{"path": "/var", "minFree": "2Gi"}]}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Preflight.DiskSpace).To(HaveLen(1))
+		Expect(cfg.Preflight.DiskSpace[0].MinFree.Value()).To(Equal(int64(2 << 30)))
+	})
+	It("Logical validation failed - preflight disk space without path", func() {
+		_, err := configPgk.Load(`{"preflight": {"diskSpace": [{"minFree": "2Gi"}]}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - preflight disk space without minFree", func() {
+		_, err := configPgk.Load(`{"preflight": {"diskSpace": [{"path": "/var"}]}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - firmware min versions", func() {
+		cfg, err := configPgk.Load(`{"preflight": {"firmware": {"minVersions": {"101d": "22.36.1010"}, "policy": "warn"}}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Preflight.Firmware.MinVersions).To(HaveKeyWithValue("101d", "22.36.1010"))
+		Expect(cfg.Preflight.Firmware.Policy).To(Equal(configPgk.FirmwarePolicyWarn))
+	})
+	It("Valid - firmware min versions are normalized", func() {
+		cfg, err := configPgk.Load(`{"preflight": {"firmware": {"minVersions": {"0x101D": "22.36.1010", "1021": "28.39.1002"}}}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Preflight.Firmware.MinVersions).To(Equal(map[string]string{"101d": "22.36.1010", "1021": "28.39.1002"}))
+	})
+	It("Logical validation failed - invalid firmware device ID", func() {
+		_, err := configPgk.Load(`{"preflight": {"firmware": {"minVersions": {"ConnectX-6": "22.36.1010"}}}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - duplicate firmware device ID", func() {
+		_, err := configPgk.Load(`{"preflight": {"firmware": {"minVersions": {"101d": "22.36.1010", "0x101d": "22.38.1002"}}}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - invalid firmware version", func() {
+		_, err := configPgk.Load(`{"preflight": {"firmware": {"minVersions": {"101d": "latest"}}}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - invalid firmware policy", func() {
+		_, err := configPgk.Load(`{"preflight": {"firmware": {"policy": "ignore"}}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - heartbeat with default timeout", func() {
+		cfg, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "annotation": "something", "heartbeatInterval": "10s"}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.SafeDriverLoad.GetHeartbeatTimeout()).To(Equal(30 * time.Second))
+	})
+	It("Valid - heartbeat disabled", func() {
+		cfg, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "annotation": "something", "heartbeatTimeout": "10s"}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.SafeDriverLoad.GetHeartbeatTimeout()).To(BeZero())
+	})
+	It("Logical validation failed - heartbeat timeout less than interval", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"heartbeatInterval": "10s", "heartbeatTimeout": "5s"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - heartbeat timeout equal to interval", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"heartbeatInterval": "10s", "heartbeatTimeout": "10s"}}`)
+		Expect(err).To(MatchError(ContainSubstring("heartbeatTimeout should be greater than")))
+	})
+	It("Logical validation failed - heartbeat timeout less than 1s", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"heartbeatInterval": "100ms", "heartbeatTimeout": "500ms"}}`)
+		Expect(err).To(MatchError(ContainSubstring("heartbeatTimeout should be at least 1s")))
+		_, err = configPgk.Load(`{"safeDriverLoad": {"heartbeatInterval": "200ms"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - condition wait mode", func() {
+		cfg, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "waitMode": "condition",
+			"condition": {"release": "node.metadata.labels[\"foo\"] == \"bar\"", "failure": "false"}}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.SafeDriverLoad.GetWaitMode()).To(Equal(configPgk.WaitModeCondition))
+	})
+	It("Logical validation failed - condition wait mode without release expression", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "waitMode": "condition"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - condition wait mode without release expression if safeDriverLoad is disabled", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": false, "waitMode": "condition"}}`)
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It("Logical validation failed - invalid failure expression", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "waitMode": "condition",
+			"condition": {"release": "true", "failure": "node.metadata["}}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - invalid wait mode", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "annotation": "something", "waitMode": "foo"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - additional gates", func() {
+		cfg, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "annotation": "foo.bar/a",
+			"additionalAnnotations": ["foo.bar/b"], "additionalLabels": ["foo.bar/c"]}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.SafeDriverLoad.AdditionalAnnotations).To(Equal([]string{"foo.bar/b"}))
+		Expect(cfg.SafeDriverLoad.AdditionalLabels).To(Equal([]string{"foo.bar/c"}))
+	})
+	It("Logical validation failed - duplicated additional annotation", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "annotation": "foo.bar/a",
+			"additionalAnnotations": ["foo.bar/a"]}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - invalid additional label", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "annotation": "foo.bar/a",
+			"additionalLabels": ["foo.bar/c/d"]}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - label gate type", func() {
+		cfg, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "gateType": "label", "label": "foo.bar/gate"}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.SafeDriverLoad.GetGateType()).To(Equal(configPgk.GateTypeLabel))
+	})
+	It("Logical validation failed - label gate type without label", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "gateType": "label", "annotation": "something"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - invalid gate type", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "gateType": "taint", "annotation": "something"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - nodeMaintenance wait mode with defaults", func() {
+		cfg, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "waitMode": "nodeMaintenance"}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.SafeDriverLoad.NodeMaintenance.GetGroupVersionKind().String()).To(
+			Equal("maintenance.nvidia.com/v1alpha1, Kind=NodeMaintenance"))
+		Expect(cfg.SafeDriverLoad.NodeMaintenance.GetNamespace()).To(Equal("default"))
+		Expect(cfg.SafeDriverLoad.NodeMaintenance.GetRequestorID()).To(Equal("network-operator-init-container"))
+	})
+	It("Logical validation failed - nodeMaintenance wait mode with invalid namespace", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "waitMode": "nodeMaintenance",
+			"nodeMaintenance": {"namespace": "Foo_Bar"}}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - drain", func() {
+		cfg, err := configPgk.Load(`{"drain": {"enable": true, "podResources": ["nvidia.com/*", "rdma/*"]}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Drain.PodResources).To(HaveLen(2))
+		Expect(cfg.Drain.GetTimeout()).To(Equal(configPgk.DefaultDrainTimeout))
+	})
+	It("Logical validation failed - drain with invalid resource pattern", func() {
+		_, err := configPgk.Load(`{"drain": {"enable": true, "podResources": ["nvidia.com/["]}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - wait for workloads", func() {
+		cfg, err := configPgk.Load(`{"waitForWorkloads": {"enable": true, "resources": ["rdma/*"], "hostRDMADevices": true}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.WaitForWorkloads.HostRDMADevices).To(BeTrue())
+	})
+	It("Logical validation failed - wait for workloads without resources", func() {
+		_, err := configPgk.Load(`{"waitForWorkloads": {"enable": true}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - wait for previous pod with label selector", func() {
+		cfg, err := configPgk.Load(`{"waitForPreviousPod": {"enable": true, "labelSelector": {"matchLabels": {"app": "driver"}}}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.WaitForPreviousPod.LabelSelector.MatchLabels).To(HaveKeyWithValue("app", "driver"))
+	})
+	It("Logical validation failed - wait for previous pod with invalid label selector", func() {
+		_, err := configPgk.Load(`{"waitForPreviousPod": {"enable": true,
+			"labelSelector": {"matchExpressions": [{"key": "app", "operator": "Foo"}]}}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - node lock", func() {
+		cfg, err := configPgk.Load(`{"nodeLock": {"enable": true, "policy": "fail"}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.NodeLock.Policy).To(Equal(configPgk.NodeLockPolicyFail))
+		Expect(cfg.NodeLock.GetLeaseDuration()).To(Equal(configPgk.DefaultNodeLockLeaseDuration))
+	})
+	It("Logical validation failed - node lock with short lease duration", func() {
+		_, err := configPgk.Load(`{"nodeLock": {"enable": true, "leaseDuration": "100ms"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - invalid node lock policy", func() {
+		_, err := configPgk.Load(`{"nodeLock": {"enable": true, "policy": "steal"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - wait for node", func() {
+		cfg, err := configPgk.Load(`{"waitForNode": {"enable": true}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.WaitForNode.GetTimeout()).To(Equal(configPgk.DefaultWaitForNodeTimeout))
+	})
+	It("Logical validation failed - wait for node with negative timeout", func() {
+		_, err := configPgk.Load(`{"waitForNode": {"enable": true, "timeout": "-1m"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Valid - configMap handshake object", func() {
+		cfg, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "annotation": "foo",
+			"handshakeObject": "configMap", "handshakeNamespace": "network-operator"}}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.SafeDriverLoad.GetHandshakeObject()).To(Equal(configPgk.HandshakeObjectConfigMap))
+	})
+	It("Logical validation failed - invalid handshake object", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "annotation": "foo",
+			"handshakeObject": "secret"}}`)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Logical validation failed - configMap handshake object in condition wait mode", func() {
+		_, err := configPgk.Load(`{"safeDriverLoad": {"enable": true, "waitMode": "condition",
+			"condition": {"release": "true"}, "handshakeObject": "configMap"}}`)
+		Expect(err).To(HaveOccurred())
+	})
 })
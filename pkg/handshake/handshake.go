@@ -0,0 +1,102 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package handshake
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LegacyValue is the value of the annotation if the payload is empty
+const LegacyValue = "true"
+
+// LabelValueWaiting is the value of the label in the label gate type while the init container waits
+const LabelValueWaiting = "waiting"
+
+// Payload is the value of the safe driver load annotation
+type Payload struct {
+	// W3C trace context of the init container run, the operator can use it to continue the trace
+	TraceContext map[string]string `json:"traceContext,omitempty"`
+	// time of the last heartbeat of the init container
+	Heartbeat *metav1.Time `json:"heartbeat,omitempty"`
+	// the init container should be considered dead if the heartbeat is older than the timeout
+	HeartbeatTimeoutSeconds int64 `json:"heartbeatTimeoutSeconds,omitempty"`
+	// boot ID of the Node at the moment when the annotation was set
+	BootID string `json:"bootID,omitempty"`
+	// pod which set the annotation
+	Owner *Owner `json:"owner,omitempty"`
+}
+
+// Owner identifies the pod which set the annotation
+type Owner struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// UID of the pod, optional
+	UID string `json:"uid,omitempty"`
+}
+
+// Is returns true if both objects identify the same pod,
+// UIDs are compared only if both are set
+func (o *Owner) Is(other *Owner) bool {
+	if o == nil || other == nil {
+		return false
+	}
+	if o.Namespace != other.Namespace || o.Name != other.Name {
+		return false
+	}
+	return o.UID == "" || other.UID == "" || o.UID == other.UID
+}
+
+// IsEmpty returns true if the payload has no data
+func (p *Payload) IsEmpty() bool {
+	return len(p.TraceContext) == 0 && p.Heartbeat == nil && p.BootID == "" && p.Owner == nil
+}
+
+// HeartbeatExpired returns true if the heartbeat is older than the timeout,
+// returns false if the payload has no heartbeat
+func (p *Payload) HeartbeatExpired(now time.Time) bool {
+	if p.Heartbeat == nil || p.HeartbeatTimeoutSeconds <= 0 {
+		return false
+	}
+	return now.Sub(p.Heartbeat.Time) > time.Duration(p.HeartbeatTimeoutSeconds)*time.Second
+}
+
+// Encode returns the value for the annotation,
+// LegacyValue is returned for the empty payload for compatibility with older operators
+func (p *Payload) Encode() (string, error) {
+	if p.IsEmpty() {
+		return LegacyValue, nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Decode parses the value of the annotation, values which are not JSON objects,
+// e.g. LegacyValue, are decoded as the empty payload
+func Decode(value string) (*Payload, error) {
+	p := &Payload{}
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(value), p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,69 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package handshake_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/handshake"
+)
+
+var _ = Describe("Annotation payload", func() {
+	It("Empty payload is encoded as legacy value", func() {
+		value, err := (&handshake.Payload{}).Encode()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(handshake.LegacyValue))
+	})
+	It("Encode and decode", func() {
+		p := &handshake.Payload{TraceContext: map[string]string{
+			"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}}
+		value, err := p.Encode()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(ContainSubstring("traceparent"))
+		decoded, err := handshake.Decode(value)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decoded).To(Equal(p))
+	})
+	It("Decode legacy value", func() {
+		p, err := handshake.Decode(handshake.LegacyValue)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.IsEmpty()).To(BeTrue())
+	})
+	It("Decode invalid JSON", func() {
+		_, err := handshake.Decode("{invalid")
+		Expect(err).To(HaveOccurred())
+	})
+	It("Heartbeat expired", func() {
+		now := time.Now()
+		p := &handshake.Payload{Heartbeat: &metav1.Time{Time: now.Add(-time.Minute)}, HeartbeatTimeoutSeconds: 30}
+		Expect(p.IsEmpty()).To(BeFalse())
+		Expect(p.HeartbeatExpired(now)).To(BeTrue())
+		Expect(p.HeartbeatExpired(now.Add(-45 * time.Second))).To(BeFalse())
+	})
+	It("Heartbeat never expires without heartbeat", func() {
+		Expect((&handshake.Payload{HeartbeatTimeoutSeconds: 30}).HeartbeatExpired(time.Now())).To(BeFalse())
+	})
+	It("Owner", func() {
+		owner := &handshake.Owner{Namespace: "ns", Name: "pod", UID: "uid1"}
+		Expect(owner.Is(&handshake.Owner{Namespace: "ns", Name: "pod", UID: "uid1"})).To(BeTrue())
+		Expect(owner.Is(&handshake.Owner{Namespace: "ns", Name: "pod"})).To(BeTrue())
+		Expect(owner.Is(&handshake.Owner{Namespace: "ns", Name: "pod", UID: "uid2"})).To(BeFalse())
+		Expect(owner.Is(&handshake.Owner{Namespace: "ns", Name: "other"})).To(BeFalse())
+		Expect(owner.Is(nil)).To(BeFalse())
+	})
+})
@@ -0,0 +1,125 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PCIDevicesPath is the path to PCI devices in sysfs relative to the host root
+	PCIDevicesPath = "sys/bus/pci/devices"
+	// InfinibandClassPath is the path to RDMA devices in sysfs relative to the host root
+	InfinibandClassPath = "sys/class/infiniband"
+
+	// MellanoxVendorID is the PCI vendor ID of NVIDIA/Mellanox network devices
+	MellanoxVendorID = "15b3"
+)
+
+// Device contains information about NVIDIA/Mellanox PCI function
+type Device struct {
+	// PCI address of the function, e.g. 0000:08:00.0
+	PCIAddress string `json:"pciAddress"`
+	// PCI device ID, e.g. 101d
+	DeviceID string `json:"deviceID"`
+	// names of the network interfaces of the function
+	NetDevs []string `json:"netDevs,omitempty"`
+	// name of the RDMA device of the function, e.g. mlx5_0
+	RDMADevice string `json:"rdmaDevice,omitempty"`
+	// firmware version of the device, e.g. 22.36.1010
+	FirmwareVersion string `json:"firmwareVersion,omitempty"`
+}
+
+// Discover returns NVIDIA/Mellanox PCI functions found in sysfs of the host root filesystem
+func Discover(hostRoot string) ([]Device, error) {
+	pciPath := filepath.Join(hostRoot, PCIDevicesPath)
+	entries, err := os.ReadDir(pciPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PCI devices: %v", err)
+	}
+	devices := []Device{}
+	for _, e := range entries {
+		devPath := filepath.Join(pciPath, e.Name())
+		vendor, err := readID(filepath.Join(devPath, "vendor"))
+		if err != nil {
+			return nil, err
+		}
+		if vendor != MellanoxVendorID {
+			continue
+		}
+		deviceID, err := readID(filepath.Join(devPath, "device"))
+		if err != nil {
+			return nil, err
+		}
+		dev := Device{PCIAddress: e.Name(), DeviceID: deviceID}
+		if dev.NetDevs, err = listDir(filepath.Join(devPath, "net")); err != nil {
+			return nil, err
+		}
+		rdmaDevs, err := listDir(filepath.Join(devPath, "infiniband"))
+		if err != nil {
+			return nil, err
+		}
+		if len(rdmaDevs) > 0 {
+			dev.RDMADevice = rdmaDevs[0]
+			if dev.FirmwareVersion, err = readFile(
+				filepath.Join(hostRoot, InfinibandClassPath, dev.RDMADevice, "fw_ver")); err != nil {
+				return nil, err
+			}
+		}
+		devices = append(devices, dev)
+	}
+	return devices, nil
+}
+
+// readID reads PCI ID from sysfs file and returns it without 0x prefix
+func readID(path string) (string, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.ToLower(data), "0x"), nil
+}
+
+// readFile returns trimmed content of the file, returns empty string if the file doesn't exist
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// listDir returns names of the entries in the directory, returns nil if the directory doesn't exist
+func listDir(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
@@ -0,0 +1,81 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package inventory_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/inventory"
+)
+
+func writeHostFile(hostRoot, path, data string) {
+	fullPath := filepath.Join(hostRoot, path)
+	ExpectWithOffset(1, os.MkdirAll(filepath.Dir(fullPath), 0o755)).NotTo(HaveOccurred())
+	ExpectWithOffset(1, os.WriteFile(fullPath, []byte(data), 0o644)).NotTo(HaveOccurred())
+}
+
+func createPCIDevice(hostRoot, address, vendor, device string) string {
+	devPath := filepath.Join(inventory.PCIDevicesPath, address)
+	writeHostFile(hostRoot, filepath.Join(devPath, "vendor"), vendor+"\n")
+	writeHostFile(hostRoot, filepath.Join(devPath, "device"), device+"\n")
+	return devPath
+}
+
+var _ = Describe("Device inventory", func() {
+	var hostRoot string
+
+	BeforeEach(func() {
+		hostRoot = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(hostRoot, inventory.PCIDevicesPath), 0o755)).NotTo(HaveOccurred())
+	})
+
+	It("No devices", func() {
+		createPCIDevice(hostRoot, "0000:00:1f.0", "0x8086", "0x7a04")
+		devices, err := inventory.Discover(hostRoot)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(devices).To(BeEmpty())
+	})
+	It("Discover devices", func() {
+		devPath := createPCIDevice(hostRoot, "0000:08:00.0", "0x15b3", "0x101d")
+		Expect(os.MkdirAll(filepath.Join(hostRoot, devPath, "net", "eth0"), 0o755)).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(hostRoot, devPath, "infiniband", "mlx5_0"), 0o755)).NotTo(HaveOccurred())
+		writeHostFile(hostRoot, filepath.Join(inventory.InfinibandClassPath, "mlx5_0", "fw_ver"), "22.36.1010\n")
+		createPCIDevice(hostRoot, "0000:08:00.1", "0x15b3", "0x101e")
+		createPCIDevice(hostRoot, "0000:00:1f.0", "0x8086", "0x7a04")
+
+		devices, err := inventory.Discover(hostRoot)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(devices).To(Equal([]inventory.Device{
+			{
+				PCIAddress:      "0000:08:00.0",
+				DeviceID:        "101d",
+				NetDevs:         []string{"eth0"},
+				RDMADevice:      "mlx5_0",
+				FirmwareVersion: "22.36.1010",
+			},
+			{
+				PCIAddress: "0000:08:00.1",
+				DeviceID:   "101e",
+			},
+		}))
+	})
+	It("No sysfs", func() {
+		_, err := inventory.Discover(filepath.Join(hostRoot, "not-exist"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,135 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	ctrlMetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "network_operator_init_container"
+
+var (
+	// Phase reports the current phase of the init container, the value is 1 for the current phase
+	Phase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "phase",
+		Help:      "Current phase of the init container, the value is 1 for the current phase and 0 for others",
+	}, []string{"phase"})
+	// WaitDuration reports time spent waiting for the safe driver load annotation removal
+	WaitDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "wait_duration_seconds",
+		Help:      "Time spent waiting for the safe driver load annotation removal",
+		// 10s .. ~11h
+		Buckets: prometheus.ExponentialBuckets(10, 2, 13),
+	})
+	// APIErrors counts failed requests to the Kubernetes API
+	APIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_errors_total",
+		Help:      "Number of failed requests to the Kubernetes API by verb",
+	}, []string{"verb"})
+	// APIRetries counts retries of requests to the Kubernetes API which failed with transient errors
+	APIRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_retries_total",
+		Help:      "Number of retries of requests to the Kubernetes API which failed with transient errors by verb",
+	}, []string{"verb"})
+	// ConfigLoads counts loads of the configuration
+	ConfigLoads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_loads_total",
+		Help:      "Number of configuration loads by result",
+	}, []string{"result"})
+
+	registerOnce sync.Once
+	phaseLock    sync.Mutex
+	currentPhase string
+)
+
+// Register registers metrics in the controller-runtime metrics registry
+func Register() {
+	registerOnce.Do(func() {
+		ctrlMetrics.Registry.MustRegister(Phase, WaitDuration, APIErrors, APIRetries, ConfigLoads)
+	})
+}
+
+// SetPhase sets value of the Phase metric to 1 for the phase and to 0 for the previous phase
+func SetPhase(phase string) {
+	phaseLock.Lock()
+	defer phaseLock.Unlock()
+	if currentPhase != "" {
+		Phase.WithLabelValues(currentPhase).Set(0)
+	}
+	Phase.WithLabelValues(phase).Set(1)
+	currentPhase = phase
+}
+
+// ConfigLoaded increments ConfigLoads metric, err is the result of the load
+func ConfigLoaded(err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	ConfigLoads.WithLabelValues(result).Inc()
+}
+
+// InstrumentClient returns client which counts failed requests in the APIErrors metric
+func InstrumentClient(c client.WithWatch) client.WithWatch {
+	return interceptor.NewClient(c, interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey,
+			obj client.Object, opts ...client.GetOption) error {
+			return countErr("get", c.Get(ctx, key, obj, opts...))
+		},
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			return countErr("list", c.List(ctx, list, opts...))
+		},
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			return countErr("create", c.Create(ctx, obj, opts...))
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			return countErr("delete", c.Delete(ctx, obj, opts...))
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			return countErr("update", c.Update(ctx, obj, opts...))
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object,
+			patch client.Patch, opts ...client.PatchOption) error {
+			return countErr("patch", c.Patch(ctx, obj, patch, opts...))
+		},
+		SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string,
+			obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+			return countErr("create", c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...))
+		},
+		Watch: func(ctx context.Context, c client.WithWatch, list client.ObjectList,
+			opts ...client.ListOption) (watch.Interface, error) {
+			w, err := c.Watch(ctx, list, opts...)
+			return w, countErr("watch", err)
+		},
+	})
+}
+
+func countErr(verb string, err error) error {
+	if err != nil {
+		APIErrors.WithLabelValues(verb).Inc()
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/metrics"
+)
+
+var _ = Describe("Metrics", func() {
+	It("Set phase", func() {
+		metrics.SetPhase("First")
+		Expect(testutil.ToFloat64(metrics.Phase.WithLabelValues("First"))).To(Equal(1.0))
+		metrics.SetPhase("Second")
+		Expect(testutil.ToFloat64(metrics.Phase.WithLabelValues("First"))).To(Equal(0.0))
+		Expect(testutil.ToFloat64(metrics.Phase.WithLabelValues("Second"))).To(Equal(1.0))
+	})
+	It("Config loads", func() {
+		before := testutil.ToFloat64(metrics.ConfigLoads.WithLabelValues("error"))
+		metrics.ConfigLoaded(fmt.Errorf("test"))
+		Expect(testutil.ToFloat64(metrics.ConfigLoads.WithLabelValues("error"))).To(Equal(before + 1))
+	})
+	It("Instrumented client counts errors", func() {
+		ctx := context.Background()
+		c := metrics.InstrumentClient(fake.NewClientBuilder().WithObjects(
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}).Build())
+		before := testutil.ToFloat64(metrics.APIErrors.WithLabelValues("get"))
+		Expect(c.Get(ctx, types.NamespacedName{Name: "node1"}, &corev1.Node{})).NotTo(HaveOccurred())
+		Expect(testutil.ToFloat64(metrics.APIErrors.WithLabelValues("get"))).To(Equal(before))
+		Expect(c.Get(ctx, types.NamespacedName{Name: "node2"}, &corev1.Node{})).To(HaveOccurred())
+		Expect(testutil.ToFloat64(metrics.APIErrors.WithLabelValues("get"))).To(Equal(before + 1))
+		before = testutil.ToFloat64(metrics.APIErrors.WithLabelValues("patch"))
+		Expect(c.Patch(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+			client.RawPatch(types.MergePatchType, []byte(`{}`)))).To(HaveOccurred())
+		Expect(testutil.ToFloat64(metrics.APIErrors.WithLabelValues("patch"))).To(Equal(before + 1))
+	})
+})
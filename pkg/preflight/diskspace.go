@@ -0,0 +1,49 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package preflight
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReasonInsufficientDiskSpace is the reason reported when there is not enough free space on the host path
+const ReasonInsufficientDiskSpace = "InsufficientDiskSpace"
+
+// FreeSpace returns amount of bytes available to unprivileged users on the filesystem with the path
+func FreeSpace(path string) (uint64, error) {
+	stat := unix.Statfs_t{}
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("failed to read filesystem stats for %s: %v", path, err)
+	}
+	//nolint:gosec
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// CheckDiskSpace returns Error if free space for the path on the host is less than minFree bytes
+func CheckDiskSpace(hostRoot, path string, minFree uint64) (uint64, error) {
+	free, err := FreeSpace(filepath.Join(hostRoot, path))
+	if err != nil {
+		return 0, err
+	}
+	if free < minFree {
+		return free, &Error{
+			Reason:  ReasonInsufficientDiskSpace,
+			Message: fmt.Sprintf("free space on %s is %d bytes, required %d bytes", path, free, minFree),
+		}
+	}
+	return free, nil
+}
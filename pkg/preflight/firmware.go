@@ -0,0 +1,118 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package preflight
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/inventory"
+)
+
+const (
+	// ReasonFirmwareTooOld is the reason reported when firmware of the device is older than required
+	ReasonFirmwareTooOld = "FirmwareTooOld"
+	// ReasonFirmwareUnknown is the reason reported when firmware version of the device can't be read,
+	// e.g. the device has no RDMA device because the driver is not bound
+	ReasonFirmwareUnknown = "FirmwareUnknown"
+)
+
+// CheckFirmware returns Error if firmware version of any device is lower than
+// minimal version configured for the device ID in minVersions or if firmware version
+// of the device with configured minimal version is unknown
+func CheckFirmware(devices []inventory.Device, minVersions map[string]string) error {
+	outdated := []string{}
+	unknown := []string{}
+	for _, dev := range devices {
+		minVersion, ok := minVersions[dev.DeviceID]
+		if !ok {
+			continue
+		}
+		if dev.FirmwareVersion == "" {
+			unknown = append(unknown, fmt.Sprintf("%s (%s)", dev.PCIAddress, dev.DeviceID))
+			continue
+		}
+		cmp, err := CompareFirmwareVersions(dev.FirmwareVersion, minVersion)
+		if err != nil {
+			return fmt.Errorf("failed to check firmware of %s: %v", dev.PCIAddress, err)
+		}
+		if cmp < 0 {
+			outdated = append(outdated, fmt.Sprintf("%s (%s): %s < %s",
+				dev.PCIAddress, dev.DeviceID, dev.FirmwareVersion, minVersion))
+		}
+	}
+	msgs := []string{}
+	if len(outdated) > 0 {
+		msgs = append(msgs, "firmware is older than required: "+strings.Join(outdated, ", "))
+	}
+	if len(unknown) > 0 {
+		msgs = append(msgs, "firmware version is unknown: "+strings.Join(unknown, ", "))
+	}
+	switch {
+	case len(outdated) > 0:
+		return &Error{Reason: ReasonFirmwareTooOld, Message: strings.Join(msgs, "; ")}
+	case len(unknown) > 0:
+		return &Error{Reason: ReasonFirmwareUnknown, Message: strings.Join(msgs, "; ")}
+	}
+	return nil
+}
+
+// CompareFirmwareVersions compares dot-separated numeric firmware versions, e.g. 22.36.1010,
+// returns -1 if a < b, 0 if a == b and 1 if a > b
+func CompareFirmwareVersions(a, b string) (int, error) {
+	aParts, err := ParseFirmwareVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bParts, err := ParseFirmwareVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart uint64
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if aPart < bPart {
+			return -1, nil
+		}
+		if aPart > bPart {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// ParseFirmwareVersion parses dot-separated numeric firmware version,
+// suffix separated with a space is ignored, e.g. "22.36.1010 (MT_0000000359)"
+func ParseFirmwareVersion(version string) ([]uint64, error) {
+	fields := strings.Fields(version)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty firmware version")
+	}
+	parts := strings.Split(fields[0], ".")
+	result := make([]uint64, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid firmware version %q", version)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
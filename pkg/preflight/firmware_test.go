@@ -0,0 +1,71 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package preflight_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/inventory"
+	"github.com/Mellanox/network-operator-init-container/pkg/preflight"
+)
+
+var _ = Describe("Firmware preflight check", func() {
+	devices := []inventory.Device{
+		{PCIAddress: "0000:08:00.0", DeviceID: "101d", FirmwareVersion: "22.36.1010"},
+		{PCIAddress: "0000:09:00.0", DeviceID: "1021", FirmwareVersion: "28.39.1002 (MT_0000000838)"},
+		{PCIAddress: "0000:0a:00.0", DeviceID: "101e"},
+	}
+
+	DescribeTable("Compare versions",
+		func(a, b string, expected int) {
+			cmp, err := preflight.CompareFirmwareVersions(a, b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmp).To(Equal(expected))
+		},
+		Entry("equal", "22.36.1010", "22.36.1010", 0),
+		Entry("less", "22.35.1010", "22.36.1000", -1),
+		Entry("greater", "22.36.1010", "22.36.1000", 1),
+		Entry("numeric compare", "22.36.998", "22.36.1000", -1),
+		Entry("different length", "22.36", "22.36.1", -1),
+		Entry("with suffix", "28.39.1002 (MT_0000000838)", "28.39.1002", 0),
+	)
+	It("Invalid version", func() {
+		_, err := preflight.CompareFirmwareVersions("22.x.1010", "22.36.1010")
+		Expect(err).To(HaveOccurred())
+	})
+	It("Firmware is up to date", func() {
+		Expect(preflight.CheckFirmware(devices, map[string]string{
+			"101d": "22.36.1010", "1021": "28.39.1000",
+		})).NotTo(HaveOccurred())
+	})
+	It("Firmware is unknown", func() {
+		err := preflight.CheckFirmware(devices, map[string]string{"101d": "22.36.1010", "101e": "99.0.0"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*preflight.Error).Reason).To(Equal(preflight.ReasonFirmwareUnknown))
+		Expect(err.Error()).To(ContainSubstring("0000:0a:00.0"))
+	})
+	It("Firmware is outdated and unknown", func() {
+		err := preflight.CheckFirmware(devices, map[string]string{"101d": "22.38.1002", "101e": "99.0.0"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*preflight.Error).Reason).To(Equal(preflight.ReasonFirmwareTooOld))
+		Expect(err.Error()).To(And(ContainSubstring("0000:08:00.0"), ContainSubstring("0000:0a:00.0")))
+	})
+	It("Firmware is outdated", func() {
+		err := preflight.CheckFirmware(devices, map[string]string{"101d": "22.38.1002"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*preflight.Error).Reason).To(Equal(preflight.ReasonFirmwareTooOld))
+		Expect(err.Error()).To(ContainSubstring("0000:08:00.0"))
+	})
+})
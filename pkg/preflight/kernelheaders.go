@@ -0,0 +1,81 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReasonKernelHeadersMissing is the reason reported when kernel headers are not installed on the host
+const ReasonKernelHeadersMissing = "KernelHeadersMissing"
+
+// KernelRelease returns release of the running kernel
+func KernelRelease() (string, error) {
+	uts := unix.Utsname{}
+	if err := unix.Uname(&uts); err != nil {
+		return "", fmt.Errorf("failed to read kernel release: %v", err)
+	}
+	return unix.ByteSliceToString(uts.Release[:]), nil
+}
+
+// CheckKernelHeaders returns Error if build directory for the provided
+// kernel release doesn't exist in the host root filesystem
+func CheckKernelHeaders(hostRoot, kernelRelease string) error {
+	buildDir := filepath.Join("/lib/modules", kernelRelease, "build")
+	path, err := resolveHostPath(hostRoot, buildDir)
+	if err == nil {
+		var info os.FileInfo
+		info, err = os.Stat(path)
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("not a directory")
+		}
+	}
+	if err != nil {
+		return &Error{
+			Reason:  ReasonKernelHeadersMissing,
+			Message: fmt.Sprintf("kernel headers for %s are not available at %s: %v", kernelRelease, buildDir, err),
+		}
+	}
+	return nil
+}
+
+// resolveHostPath returns path inside the container for the path on the host,
+// absolute symlink targets are resolved relative to the host root
+func resolveHostPath(hostRoot, path string) (string, error) {
+	fullPath := filepath.Join(hostRoot, path)
+	// limit number of followed links to protect from loops
+	for i := 0; i < 255; i++ {
+		info, err := os.Lstat(fullPath)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fullPath, nil
+		}
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			fullPath = filepath.Join(hostRoot, target)
+		} else {
+			fullPath = filepath.Join(filepath.Dir(fullPath), target)
+		}
+	}
+	return "", fmt.Errorf("too many levels of symbolic links")
+}
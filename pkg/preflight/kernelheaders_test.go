@@ -0,0 +1,74 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package preflight_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/preflight"
+)
+
+var _ = Describe("Build prerequisites preflight checks", func() {
+	const testRelease = "6.1.0-test"
+	var hostRoot string
+
+	BeforeEach(func() {
+		hostRoot = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(hostRoot, "lib/modules", testRelease), 0o755)).NotTo(HaveOccurred())
+	})
+
+	It("Kernel release", func() {
+		release, err := preflight.KernelRelease()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(release).NotTo(BeEmpty())
+	})
+	It("Kernel headers - directory", func() {
+		Expect(os.MkdirAll(filepath.Join(hostRoot, "lib/modules", testRelease, "build"), 0o755)).NotTo(HaveOccurred())
+		Expect(preflight.CheckKernelHeaders(hostRoot, testRelease)).NotTo(HaveOccurred())
+	})
+	It("Kernel headers - absolute symlink is resolved relative to the host root", func() {
+		Expect(os.MkdirAll(filepath.Join(hostRoot, "usr/src/linux-headers"), 0o755)).NotTo(HaveOccurred())
+		Expect(os.Symlink("/usr/src/linux-headers",
+			filepath.Join(hostRoot, "lib/modules", testRelease, "build"))).NotTo(HaveOccurred())
+		Expect(preflight.CheckKernelHeaders(hostRoot, testRelease)).NotTo(HaveOccurred())
+	})
+	It("Kernel headers - broken symlink", func() {
+		Expect(os.Symlink("/usr/src/linux-headers",
+			filepath.Join(hostRoot, "lib/modules", testRelease, "build"))).NotTo(HaveOccurred())
+		err := preflight.CheckKernelHeaders(hostRoot, testRelease)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*preflight.Error).Reason).To(Equal(preflight.ReasonKernelHeadersMissing))
+	})
+	It("Kernel headers - missing", func() {
+		Expect(preflight.CheckKernelHeaders(hostRoot, testRelease)).To(HaveOccurred())
+	})
+	It("Disk space - enough", func() {
+		free, err := preflight.CheckDiskSpace(hostRoot, "/", 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(free).To(BeNumerically(">", 0))
+	})
+	It("Disk space - not enough", func() {
+		_, err := preflight.CheckDiskSpace(hostRoot, "/", 1<<62)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*preflight.Error).Reason).To(Equal(preflight.ReasonInsufficientDiskSpace))
+	})
+	It("Disk space - path not found", func() {
+		_, err := preflight.CheckDiskSpace(hostRoot, "/not-exist", 1)
+		Expect(err).To(HaveOccurred())
+	})
+})
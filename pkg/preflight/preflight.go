@@ -0,0 +1,43 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package preflight
+
+import "fmt"
+
+// Error is returned when a preflight check detects that the driver can't be loaded on the host
+type Error struct {
+	// machine-readable reason of the failure
+	Reason string
+	// human-readable description of the failure
+	Message string
+}
+
+// Error implements error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Message)
+}
+
+// Result contains result of a single preflight check
+type Result struct {
+	// name of the check
+	Check string `json:"check"`
+	// the check passed
+	Passed bool `json:"passed"`
+	// the check failed, but the failure doesn't block the driver loading
+	Warning bool `json:"warning,omitempty"`
+	// machine-readable reason of the failure
+	Reason string `json:"reason,omitempty"`
+	// human-readable description of the result
+	Message string `json:"message"`
+}
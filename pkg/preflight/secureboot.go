@@ -0,0 +1,105 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// LockdownPath is the path to the kernel lockdown state file relative to the host root
+	LockdownPath = "sys/kernel/security/lockdown"
+	// SecureBootEFIVarPath is the path to the SecureBoot EFI variable relative to the host root
+	SecureBootEFIVarPath = "sys/firmware/efi/efivars/SecureBoot-8be4df61-93ca-11d2-aa0d-e0b12c9e1c22"
+
+	// LockdownNone means that kernel lockdown is disabled
+	LockdownNone = "none"
+	// LockdownUnknown means that kernel lockdown state can't be detected
+	LockdownUnknown = "unknown"
+
+	// ReasonUnsignedDriverRejected is the reason reported when the kernel will reject unsigned driver
+	ReasonUnsignedDriverRejected = "UnsignedDriverRejected"
+)
+
+// SecureBootStatus contains Secure Boot and kernel lockdown state of the host
+type SecureBootStatus struct {
+	// Secure Boot is enabled in the firmware
+	SecureBoot bool
+	// active kernel lockdown mode, e.g. none, integrity, confidentiality
+	Lockdown string
+}
+
+// UnsignedModulesRejected returns true if the kernel will refuse to load unsigned modules
+func (s *SecureBootStatus) UnsignedModulesRejected() bool {
+	return s.SecureBoot || (s.Lockdown != LockdownNone && s.Lockdown != LockdownUnknown)
+}
+
+// GetSecureBootStatus reads Secure Boot and kernel lockdown state from the host
+// root filesystem mounted at hostRoot
+func GetSecureBootStatus(hostRoot string) (*SecureBootStatus, error) {
+	status := &SecureBootStatus{Lockdown: LockdownUnknown}
+
+	lockdown, err := os.ReadFile(filepath.Join(hostRoot, LockdownPath))
+	switch {
+	case err == nil:
+		status.Lockdown = parseLockdown(string(lockdown))
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to read kernel lockdown state: %v", err)
+	}
+
+	secureBoot, err := os.ReadFile(filepath.Join(hostRoot, SecureBootEFIVarPath))
+	switch {
+	case err == nil:
+		// first 4 bytes contain attributes of the EFI variable, the value follows them
+		if len(secureBoot) < 5 {
+			return nil, fmt.Errorf("unexpected format of the SecureBoot EFI variable")
+		}
+		status.SecureBoot = secureBoot[4] == 1
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to read SecureBoot EFI variable: %v", err)
+	}
+	return status, nil
+}
+
+// parseLockdown returns the active lockdown mode,
+// the active mode is enclosed in square brackets, e.g. "none [integrity] confidentiality"
+func parseLockdown(data string) string {
+	for _, mode := range strings.Fields(data) {
+		if strings.HasPrefix(mode, "[") && strings.HasSuffix(mode, "]") {
+			return strings.Trim(mode, "[]")
+		}
+	}
+	return LockdownUnknown
+}
+
+// CheckSecureBoot returns Error if the host enforces module signatures and the driver is not signed
+func CheckSecureBoot(hostRoot string, driverSigned bool) (*SecureBootStatus, error) {
+	status, err := GetSecureBootStatus(hostRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !driverSigned && status.UnsignedModulesRejected() {
+		return status, &Error{
+			Reason: ReasonUnsignedDriverRejected,
+			Message: fmt.Sprintf("unsigned driver will be rejected by the kernel, secureBoot: %t, lockdown: %s",
+				status.SecureBoot, status.Lockdown),
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,80 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package preflight_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/preflight"
+)
+
+func writeHostFile(hostRoot, path string, data []byte) {
+	fullPath := filepath.Join(hostRoot, path)
+	ExpectWithOffset(1, os.MkdirAll(filepath.Dir(fullPath), 0o755)).NotTo(HaveOccurred())
+	ExpectWithOffset(1, os.WriteFile(fullPath, data, 0o644)).NotTo(HaveOccurred())
+}
+
+var _ = Describe("Secure Boot preflight check", func() {
+	var hostRoot string
+
+	BeforeEach(func() {
+		hostRoot = GinkgoT().TempDir()
+	})
+
+	It("No lockdown and no EFI variables", func() {
+		status, err := preflight.CheckSecureBoot(hostRoot, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.SecureBoot).To(BeFalse())
+		Expect(status.Lockdown).To(Equal(preflight.LockdownUnknown))
+	})
+	It("Lockdown disabled, Secure Boot disabled", func() {
+		writeHostFile(hostRoot, preflight.LockdownPath, []byte("[none] integrity confidentiality\n"))
+		writeHostFile(hostRoot, preflight.SecureBootEFIVarPath, []byte{0x6, 0x0, 0x0, 0x0, 0x0})
+		status, err := preflight.CheckSecureBoot(hostRoot, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.SecureBoot).To(BeFalse())
+		Expect(status.Lockdown).To(Equal(preflight.LockdownNone))
+	})
+	It("Lockdown enabled, unsigned driver", func() {
+		writeHostFile(hostRoot, preflight.LockdownPath, []byte("none [integrity] confidentiality\n"))
+		_, err := preflight.CheckSecureBoot(hostRoot, false)
+		Expect(err).To(HaveOccurred())
+		preflightErr := &preflight.Error{}
+		Expect(err).To(BeAssignableToTypeOf(preflightErr))
+		Expect(err.(*preflight.Error).Reason).To(Equal(preflight.ReasonUnsignedDriverRejected))
+	})
+	It("Secure Boot enabled, unsigned driver", func() {
+		writeHostFile(hostRoot, preflight.SecureBootEFIVarPath, []byte{0x6, 0x0, 0x0, 0x0, 0x1})
+		status, err := preflight.CheckSecureBoot(hostRoot, false)
+		Expect(err).To(HaveOccurred())
+		Expect(status.SecureBoot).To(BeTrue())
+	})
+	It("Secure Boot enabled, signed driver", func() {
+		writeHostFile(hostRoot, preflight.LockdownPath, []byte("none [integrity] confidentiality\n"))
+		writeHostFile(hostRoot, preflight.SecureBootEFIVarPath, []byte{0x6, 0x0, 0x0, 0x0, 0x1})
+		status, err := preflight.CheckSecureBoot(hostRoot, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.SecureBoot).To(BeTrue())
+		Expect(status.Lockdown).To(Equal("integrity"))
+	})
+	It("Invalid EFI variable", func() {
+		writeHostFile(hostRoot, preflight.SecureBootEFIVarPath, []byte{0x6})
+		_, err := preflight.CheckSecureBoot(hostRoot, true)
+		Expect(err).To(HaveOccurred())
+	})
+})
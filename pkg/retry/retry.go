@@ -0,0 +1,165 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-logr/logr"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/metrics"
+)
+
+const (
+	// DefaultAttempts is the default maximum number of attempts
+	DefaultAttempts = 5
+	// DefaultBackoff is the default backoff before the first retry
+	DefaultBackoff = time.Second
+	// DefaultMaxBackoff is the default maximum backoff
+	DefaultMaxBackoff = 30 * time.Second
+
+	// jitter is the maximum fraction of the backoff which is added to it
+	jitter = 0.5
+)
+
+// Policy defines retries of requests to the Kubernetes API which failed with transient errors,
+// requests which failed with permanent errors are not retried
+type Policy struct {
+	// maximum number of attempts, 1 disables retries
+	Attempts int
+	// backoff before the first retry, the backoff is doubled for every next retry
+	Backoff time.Duration
+	// maximum backoff
+	MaxBackoff time.Duration
+}
+
+// DefaultPolicy returns the default Policy
+func DefaultPolicy() Policy {
+	return Policy{Attempts: DefaultAttempts, Backoff: DefaultBackoff, MaxBackoff: DefaultMaxBackoff}
+}
+
+// IsTransient returns true if err is a transient error which can go away on retry:
+// timeouts, 429 Too Many Requests, 5xx errors and network errors.
+// Other errors, e.g. 403 Forbidden or 404 Not Found, are permanent
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if apiErrors.IsTimeout(err) || apiErrors.IsServerTimeout(err) || apiErrors.IsTooManyRequests(err) {
+		return true
+	}
+	var status apiErrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Code >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err)
+}
+
+// Do calls fn until it succeeds, fails with a permanent error, the attempts are exhausted or ctx is canceled,
+// the error of the last attempt is returned. Retries are logged and counted in the APIRetries metric
+func (p Policy) Do(ctx context.Context, logger logr.Logger, verb string, fn func() error) error {
+	return p.do(ctx, logger, verb, IsTransient, fn)
+}
+
+// do calls fn until it succeeds, fails with an error which is not retriable, the attempts are exhausted
+// or ctx is canceled
+func (p Policy) do(ctx context.Context, logger logr.Logger, verb string, retriable func(error) bool,
+	fn func() error) error {
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || !retriable(err) {
+			return err
+		}
+		delay := wait.Jitter(backoff, jitter)
+		// the API server can suggest the delay, e.g. in 429 response
+		if seconds, ok := apiErrors.SuggestsClientDelay(err); ok && time.Duration(seconds)*time.Second > delay {
+			delay = time.Duration(seconds) * time.Second
+		}
+		metrics.APIRetries.WithLabelValues(verb).Inc()
+		logger.Info("transient API error, retry", "verb", verb, "attempt", attempt, "maxAttempts", p.Attempts,
+			"backoff", delay.String(), "error", err.Error())
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// isTransientEvictionError returns true if the eviction failed with a transient error,
+// 429 means that the eviction is blocked by PodDisruptionBudget, the caller retries it on its own
+func isTransientEvictionError(err error) bool {
+	return IsTransient(err) && !apiErrors.IsTooManyRequests(err)
+}
+
+// Client returns client which retries requests according to the policy, only requests which are safe
+// to repeat are retried: watch requests and JSON patches, which can contain test operations
+// that fail if the first attempt was applied, are not retried
+func Client(c client.WithWatch, p Policy, logger logr.Logger) client.WithWatch {
+	return interceptor.NewClient(c, interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey,
+			obj client.Object, opts ...client.GetOption) error {
+			return p.Do(ctx, logger, "get", func() error { return c.Get(ctx, key, obj, opts...) })
+		},
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			return p.Do(ctx, logger, "list", func() error { return c.List(ctx, list, opts...) })
+		},
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			return p.Do(ctx, logger, "create", func() error { return c.Create(ctx, obj, opts...) })
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			return p.Do(ctx, logger, "delete", func() error { return c.Delete(ctx, obj, opts...) })
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			return p.Do(ctx, logger, "update", func() error { return c.Update(ctx, obj, opts...) })
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object,
+			patch client.Patch, opts ...client.PatchOption) error {
+			if patch.Type() == types.JSONPatchType {
+				return c.Patch(ctx, obj, patch, opts...)
+			}
+			return p.Do(ctx, logger, "patch", func() error { return c.Patch(ctx, obj, patch, opts...) })
+		},
+		SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string,
+			obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+			retriable := IsTransient
+			if subResourceName == "eviction" {
+				retriable = isTransientEvictionError
+			}
+			return p.do(ctx, logger, "create", retriable, func() error {
+				return c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...)
+			})
+		},
+	})
+}
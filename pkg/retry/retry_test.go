@@ -0,0 +1,152 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package retry_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/metrics"
+	"github.com/Mellanox/network-operator-init-container/pkg/retry"
+)
+
+var nodesResource = schema.GroupResource{Resource: "nodes"}
+
+var _ = Describe("Retry", func() {
+	policy := retry.Policy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	DescribeTable("IsTransient",
+		func(err error, transient bool) {
+			Expect(retry.IsTransient(err)).To(Equal(transient))
+		},
+		Entry("nil", nil, false),
+		Entry("timeout", apiErrors.NewTimeoutError("test", 1), true),
+		Entry("server timeout", apiErrors.NewServerTimeout(nodesResource, "get", 1), true),
+		Entry("too many requests", apiErrors.NewTooManyRequests("test", 1), true),
+		Entry("internal error", apiErrors.NewInternalError(fmt.Errorf("test")), true),
+		Entry("service unavailable", apiErrors.NewServiceUnavailable("test"), true),
+		Entry("forbidden", apiErrors.NewForbidden(nodesResource, "node1", fmt.Errorf("test")), false),
+		Entry("not found", apiErrors.NewNotFound(nodesResource, "node1"), false),
+		Entry("conflict", apiErrors.NewConflict(nodesResource, "node1", fmt.Errorf("test")), false),
+		Entry("context canceled", context.Canceled, false),
+		Entry("other error", fmt.Errorf("test"), false),
+	)
+	It("Retry transient errors", func() {
+		before := testutil.ToFloat64(metrics.APIRetries.WithLabelValues("test"))
+		calls := 0
+		err := policy.Do(context.Background(), logr.Discard(), "test", func() error {
+			calls++
+			if calls < 3 {
+				return apiErrors.NewServiceUnavailable("test")
+			}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal(3))
+		Expect(testutil.ToFloat64(metrics.APIRetries.WithLabelValues("test"))).To(Equal(before + 2))
+	})
+	It("Attempts exhausted", func() {
+		calls := 0
+		err := policy.Do(context.Background(), logr.Discard(), "test", func() error {
+			calls++
+			return apiErrors.NewServiceUnavailable("test")
+		})
+		Expect(apiErrors.IsServiceUnavailable(err)).To(BeTrue())
+		Expect(calls).To(Equal(3))
+	})
+	It("Fail fast on permanent errors", func() {
+		calls := 0
+		err := policy.Do(context.Background(), logr.Discard(), "test", func() error {
+			calls++
+			return apiErrors.NewForbidden(nodesResource, "node1", fmt.Errorf("test"))
+		})
+		Expect(apiErrors.IsForbidden(err)).To(BeTrue())
+		Expect(calls).To(Equal(1))
+	})
+	It("Canceled", func() {
+		ctx, cFunc := context.WithCancel(context.Background())
+		cFunc()
+		calls := 0
+		err := retry.Policy{Attempts: 3, Backoff: time.Hour, MaxBackoff: time.Hour}.Do(ctx, logr.Discard(), "test",
+			func() error {
+				calls++
+				return apiErrors.NewServiceUnavailable("test")
+			})
+		Expect(apiErrors.IsServiceUnavailable(err)).To(BeTrue())
+		Expect(calls).To(Equal(1))
+	})
+	It("Client retries requests", func() {
+		calls := 0
+		c := retry.Client(fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey,
+				obj client.Object, opts ...client.GetOption) error {
+				calls++
+				if calls == 1 {
+					return apiErrors.NewTooManyRequests("test", 0)
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).Build(), policy, logr.Discard())
+		err := c.Get(context.Background(), types.NamespacedName{Name: "node1"}, &corev1.Node{})
+		Expect(apiErrors.IsNotFound(err)).To(BeTrue())
+		Expect(calls).To(Equal(2))
+	})
+	It("Client doesn't retry JSON patches", func() {
+		calls := 0
+		c := retry.Client(fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object,
+				patch client.Patch, opts ...client.PatchOption) error {
+				calls++
+				return apiErrors.NewServerTimeout(nodesResource, "patch", 0)
+			},
+		}).Build(), policy, logr.Discard())
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+		err := c.Patch(context.Background(), node, client.RawPatch(types.JSONPatchType, []byte("[]")))
+		Expect(apiErrors.IsServerTimeout(err)).To(BeTrue())
+		Expect(calls).To(Equal(1))
+		calls = 0
+		err = c.Patch(context.Background(), node, client.RawPatch(types.MergePatchType, []byte("{}")))
+		Expect(apiErrors.IsServerTimeout(err)).To(BeTrue())
+		Expect(calls).To(Equal(3))
+	})
+	It("Client doesn't retry evictions blocked by PodDisruptionBudget", func() {
+		calls := 0
+		c := retry.Client(fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string,
+				obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				calls++
+				return apiErrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget", 0)
+			},
+		}).Build(), policy, logr.Discard())
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}
+		err := c.SubResource("eviction").Create(context.Background(), pod, &policyv1.Eviction{})
+		Expect(apiErrors.IsTooManyRequests(err)).To(BeTrue())
+		Expect(calls).To(Equal(1))
+	})
+})
@@ -0,0 +1,71 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package sriov
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+// netlinkVFConfigurator reads and applies settings of the VFs with netlink,
+// requires the network namespace of the host
+type netlinkVFConfigurator struct{}
+
+// NewNetlinkVFConfigurator returns VFConfigurator which uses netlink
+func NewNetlinkVFConfigurator() VFConfigurator {
+	return netlinkVFConfigurator{}
+}
+
+// VFs is part of VFConfigurator interface
+func (netlinkVFConfigurator) VFs(netDev string) ([]VFState, error) {
+	link, err := netlink.LinkByName(netDev)
+	if err != nil {
+		return nil, err
+	}
+	vfs := make([]VFState, 0, len(link.Attrs().Vfs))
+	for _, vf := range link.Attrs().Vfs {
+		state := VFState{ID: vf.ID, VLAN: vf.Vlan, QoS: vf.Qos, Trust: vf.Trust != 0, SpoofCheck: vf.Spoofchk}
+		if len(vf.Mac) != 0 && !bytes.Equal(vf.Mac, make(net.HardwareAddr, len(vf.Mac))) {
+			state.MAC = vf.Mac.String()
+		}
+		vfs = append(vfs, state)
+	}
+	return vfs, nil
+}
+
+// SetVF is part of VFConfigurator interface
+func (netlinkVFConfigurator) SetVF(netDev string, vf VFState) error {
+	link, err := netlink.LinkByName(netDev)
+	if err != nil {
+		return err
+	}
+	if vf.MAC != "" {
+		mac, err := net.ParseMAC(vf.MAC)
+		if err != nil {
+			return err
+		}
+		if err := netlink.LinkSetVfHardwareAddr(link, vf.ID, mac); err != nil {
+			return err
+		}
+	}
+	if err := netlink.LinkSetVfVlanQos(link, vf.ID, vf.VLAN, vf.QoS); err != nil {
+		return err
+	}
+	if err := netlink.LinkSetVfSpoofchk(link, vf.ID, vf.SpoofCheck); err != nil {
+		return err
+	}
+	return netlink.LinkSetVfTrust(link, vf.ID, vf.Trust)
+}
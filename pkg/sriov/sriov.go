@@ -0,0 +1,251 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package sriov
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NetClassPath is the path to network interfaces in sysfs relative to the host root
+	NetClassPath = "sys/class/net"
+	// PCIDevicesPath is the path to PCI devices in sysfs relative to the host root
+	PCIDevicesPath = "sys/bus/pci/devices"
+	// DefaultStateFile is the default path to the state file on the host
+	DefaultStateFile = "/run/network-operator-init-container/sriov-state.json"
+	// BootIDPath is the path to the boot ID of the kernel relative to the host root
+	BootIDPath = "proc/sys/kernel/random/boot_id"
+
+	numVFsFile           = "sriov_numvfs"
+	driversAutoprobeFile = "sriov_drivers_autoprobe"
+)
+
+// State contains SR-IOV configuration of the host
+type State struct {
+	// boot ID of the host at the time of the snapshot, empty if unknown
+	BootID string `json:"bootID,omitempty"`
+	// SR-IOV configuration of the physical functions
+	PFs []PFState `json:"pfs"`
+}
+
+// PFState contains SR-IOV configuration of the physical function
+type PFState struct {
+	// PCI address of the PF, used to find the PF after the driver reload
+	PCIAddress string `json:"pciAddress"`
+	// name of the network interface of the PF at the time of the snapshot
+	NetDev string `json:"netDev"`
+	// number of enabled VFs
+	NumVFs int `json:"numVfs"`
+	// value of sriov_drivers_autoprobe, nil if not supported
+	DriversAutoprobe *bool `json:"driversAutoprobe,omitempty"`
+	// settings of the VFs
+	VFs []VFState `json:"vfs,omitempty"`
+}
+
+// VFState contains settings of the VF which are configured on the PF and reset by the driver reload
+type VFState struct {
+	// index of the VF
+	ID int `json:"id"`
+	// administrative MAC address, empty if not set
+	MAC string `json:"mac,omitempty"`
+	// VLAN ID, 0 if not set
+	VLAN int `json:"vlan,omitempty"`
+	// VLAN QoS
+	QoS int `json:"qos,omitempty"`
+	// trusted mode
+	Trust bool `json:"trust"`
+	// spoof checking
+	SpoofCheck bool `json:"spoofCheck"`
+}
+
+// VFConfigurator reads and applies settings of the VFs of the PF
+type VFConfigurator interface {
+	// VFs returns settings of all VFs of the PF network interface
+	VFs(netDev string) ([]VFState, error)
+	// SetVF applies settings of the VF of the PF network interface
+	SetVF(netDev string, vf VFState) error
+}
+
+// Snapshot reads SR-IOV configuration of the PFs which have VFs enabled
+// from sysfs of the host root filesystem, settings of the VFs are read with vfs
+func Snapshot(hostRoot string, vfs VFConfigurator) (*State, error) {
+	netPath := filepath.Join(hostRoot, NetClassPath)
+	entries, err := os.ReadDir(netPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %v", err)
+	}
+	state := &State{BootID: readBootID(hostRoot), PFs: []PFState{}}
+	for _, e := range entries {
+		devPath := filepath.Join(netPath, e.Name(), "device")
+		numVFs, err := readInt(filepath.Join(devPath, numVFsFile))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if numVFs == 0 {
+			continue
+		}
+		pciDev, err := filepath.EvalSymlinks(devPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve PCI device for %s: %v", e.Name(), err)
+		}
+		pf := PFState{PCIAddress: filepath.Base(pciDev), NetDev: e.Name(), NumVFs: numVFs}
+		autoprobe, err := readInt(filepath.Join(devPath, driversAutoprobeFile))
+		switch {
+		case err == nil:
+			enabled := autoprobe != 0
+			pf.DriversAutoprobe = &enabled
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+		if pf.VFs, err = vfs.VFs(pf.NetDev); err != nil {
+			return nil, fmt.Errorf("failed to read settings of VFs of %s: %v", pf.NetDev, err)
+		}
+		state.PFs = append(state.PFs, pf)
+	}
+	return state, nil
+}
+
+// Restore applies SR-IOV configuration from the state to the PFs in sysfs of the host root filesystem,
+// settings of the VFs are applied with vfs
+func Restore(hostRoot string, state *State, vfs VFConfigurator) error {
+	for _, pf := range state.PFs {
+		devPath := filepath.Join(hostRoot, PCIDevicesPath, pf.PCIAddress)
+		if pf.DriversAutoprobe != nil {
+			value := 0
+			if *pf.DriversAutoprobe {
+				value = 1
+			}
+			if err := writeInt(filepath.Join(devPath, driversAutoprobeFile), value); err != nil {
+				return err
+			}
+		}
+		numVFsPath := filepath.Join(devPath, numVFsFile)
+		current, err := readInt(numVFsPath)
+		if err != nil {
+			return err
+		}
+		if current != pf.NumVFs {
+			// kernel rejects change of the VFs number if VFs are already enabled
+			if current != 0 {
+				if err := writeInt(numVFsPath, 0); err != nil {
+					return err
+				}
+			}
+			if err := writeInt(numVFsPath, pf.NumVFs); err != nil {
+				return err
+			}
+		}
+		if len(pf.VFs) == 0 {
+			continue
+		}
+		netDev, err := pfNetDev(devPath, pf.NetDev)
+		if err != nil {
+			return err
+		}
+		for _, vf := range pf.VFs {
+			if err := vfs.SetVF(netDev, vf); err != nil {
+				return fmt.Errorf("failed to restore settings of VF %d of %s: %v", vf.ID, netDev, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pfNetDev returns the name of the network interface of the PF, the name can change after the driver reload,
+// name from the snapshot is used if the PF has no network interface in sysfs
+func pfNetDev(devPath, snapshotNetDev string) (string, error) {
+	entries, err := os.ReadDir(filepath.Join(devPath, "net"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return snapshotNetDev, nil
+		}
+		return "", fmt.Errorf("failed to list network interfaces of the PF: %v", err)
+	}
+	if len(entries) == 0 {
+		return snapshotNetDev, nil
+	}
+	return entries[0].Name(), nil
+}
+
+// SaveState writes the state to the file on the host, parent directories are created if needed
+func SaveState(hostRoot, stateFile string, state *State) error {
+	path := filepath.Join(hostRoot, stateFile)
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for the state file: %v", err)
+	}
+	// write to the temporary file first to avoid partially written state
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write the state file: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write the state file: %v", err)
+	}
+	return nil
+}
+
+// LoadState reads the state from the file on the host
+func LoadState(hostRoot, stateFile string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(hostRoot, stateFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the state file: %w", err)
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the state file: %v", err)
+	}
+	return state, nil
+}
+
+// readBootID returns boot ID of the host, empty string if it can't be read
+func readBootID(hostRoot string) string {
+	data, err := os.ReadFile(filepath.Join(hostRoot, BootIDPath))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected content of %s: %v", path, err)
+	}
+	return v, nil
+}
+
+func writeInt(path string, value int) error {
+	if err := os.WriteFile(path, []byte(strconv.Itoa(value)), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
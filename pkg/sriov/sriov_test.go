@@ -0,0 +1,145 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package sriov_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/sriov"
+)
+
+// createPF creates fake sysfs entries for the PF
+func createPF(hostRoot, netDev, pciAddress, numVFs string) string {
+	devPath := filepath.Join(hostRoot, sriov.PCIDevicesPath, pciAddress)
+	ExpectWithOffset(1, os.MkdirAll(devPath, 0o755)).NotTo(HaveOccurred())
+	ExpectWithOffset(1, os.WriteFile(filepath.Join(devPath, "sriov_numvfs"), []byte(numVFs+"\n"), 0o644)).
+		NotTo(HaveOccurred())
+	netPath := filepath.Join(hostRoot, sriov.NetClassPath, netDev)
+	ExpectWithOffset(1, os.MkdirAll(netPath, 0o755)).NotTo(HaveOccurred())
+	ExpectWithOffset(1, os.Symlink(devPath, filepath.Join(netPath, "device"))).NotTo(HaveOccurred())
+	return devPath
+}
+
+// fakeVFConfigurator keeps settings of the VFs in memory
+type fakeVFConfigurator struct {
+	vfs map[string][]sriov.VFState
+}
+
+func (f *fakeVFConfigurator) VFs(netDev string) ([]sriov.VFState, error) {
+	return f.vfs[netDev], nil
+}
+
+func (f *fakeVFConfigurator) SetVF(netDev string, vf sriov.VFState) error {
+	for i := range f.vfs[netDev] {
+		if f.vfs[netDev][i].ID == vf.ID {
+			f.vfs[netDev][i] = vf
+			return nil
+		}
+	}
+	return fmt.Errorf("VF %d of %s not found", vf.ID, netDev)
+}
+
+func readFile(path string) string {
+	data, err := os.ReadFile(path)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	return string(data)
+}
+
+var _ = Describe("SR-IOV configuration", func() {
+	var (
+		hostRoot string
+		vfs      *fakeVFConfigurator
+	)
+
+	BeforeEach(func() {
+		vfs = &fakeVFConfigurator{vfs: map[string][]sriov.VFState{}}
+		hostRoot = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(hostRoot, sriov.NetClassPath, "lo"), 0o755)).NotTo(HaveOccurred())
+	})
+
+	It("Snapshot", func() {
+		pf0 := createPF(hostRoot, "eth0", "0000:08:00.0", "4")
+		Expect(os.WriteFile(filepath.Join(pf0, "sriov_drivers_autoprobe"), []byte("0\n"), 0o644)).
+			NotTo(HaveOccurred())
+		createPF(hostRoot, "eth1", "0000:08:00.1", "0")
+		Expect(os.MkdirAll(filepath.Join(hostRoot, filepath.Dir(sriov.BootIDPath)), 0o755)).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(hostRoot, sriov.BootIDPath), []byte("boot-1\n"), 0o644)).
+			NotTo(HaveOccurred())
+		vfs.vfs["eth0"] = []sriov.VFState{
+			{ID: 0, MAC: "02:00:00:00:00:01", VLAN: 10, Trust: true, SpoofCheck: false},
+			{ID: 1, SpoofCheck: true},
+		}
+		state, err := sriov.Snapshot(hostRoot, vfs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.BootID).To(Equal("boot-1"))
+		Expect(state.PFs).To(Equal([]sriov.PFState{{
+			PCIAddress:       "0000:08:00.0",
+			NetDev:           "eth0",
+			NumVFs:           4,
+			DriversAutoprobe: ptr.To(false),
+			VFs: []sriov.VFState{
+				{ID: 0, MAC: "02:00:00:00:00:01", VLAN: 10, Trust: true, SpoofCheck: false},
+				{ID: 1, SpoofCheck: true},
+			},
+		}}))
+	})
+	It("Save and load state", func() {
+		state := &sriov.State{PFs: []sriov.PFState{{PCIAddress: "0000:08:00.0", NetDev: "eth0", NumVFs: 2,
+			VFs: []sriov.VFState{{ID: 0, MAC: "02:00:00:00:00:01", SpoofCheck: true}, {ID: 1}}}}}
+		Expect(sriov.SaveState(hostRoot, sriov.DefaultStateFile, state)).NotTo(HaveOccurred())
+		loaded, err := sriov.LoadState(hostRoot, sriov.DefaultStateFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(Equal(state))
+	})
+	It("Load state - no file", func() {
+		_, err := sriov.LoadState(hostRoot, sriov.DefaultStateFile)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Restore", func() {
+		pf0 := createPF(hostRoot, "eth0", "0000:08:00.0", "0")
+		pf1 := createPF(hostRoot, "eth1", "0000:08:00.1", "8")
+		// the PF was renamed after the driver reload
+		Expect(os.MkdirAll(filepath.Join(pf1, "net", "enp8s0f1np1"), 0o755)).NotTo(HaveOccurred())
+		vfs.vfs["enp8s0f1np1"] = []sriov.VFState{{ID: 0, SpoofCheck: true}, {ID: 1, SpoofCheck: true}}
+		Expect(sriov.Restore(hostRoot, &sriov.State{PFs: []sriov.PFState{
+			{PCIAddress: "0000:08:00.0", NetDev: "eth0", NumVFs: 4, DriversAutoprobe: ptr.To(true)},
+			{PCIAddress: "0000:08:00.1", NetDev: "eth1", NumVFs: 2, VFs: []sriov.VFState{
+				{ID: 1, MAC: "02:00:00:00:00:02", VLAN: 20, QoS: 1, Trust: true}}},
+		}}, vfs)).NotTo(HaveOccurred())
+		Expect(readFile(filepath.Join(pf0, "sriov_numvfs"))).To(Equal("4"))
+		Expect(readFile(filepath.Join(pf0, "sriov_drivers_autoprobe"))).To(Equal("1"))
+		Expect(readFile(filepath.Join(pf1, "sriov_numvfs"))).To(Equal("2"))
+		Expect(vfs.vfs["enp8s0f1np1"]).To(Equal([]sriov.VFState{
+			{ID: 0, SpoofCheck: true},
+			{ID: 1, MAC: "02:00:00:00:00:02", VLAN: 20, QoS: 1, Trust: true},
+		}))
+	})
+	It("Restore - VF not found", func() {
+		createPF(hostRoot, "eth0", "0000:08:00.0", "0")
+		Expect(sriov.Restore(hostRoot, &sriov.State{PFs: []sriov.PFState{
+			{PCIAddress: "0000:08:00.0", NetDev: "eth0", NumVFs: 1, VFs: []sriov.VFState{{ID: 0, Trust: true}}},
+		}}, vfs)).To(MatchError(ContainSubstring("VF 0 of eth0")))
+	})
+	It("Restore - PF not found", func() {
+		Expect(sriov.Restore(hostRoot, &sriov.State{PFs: []sriov.PFState{
+			{PCIAddress: "0000:08:00.0", NetDev: "eth0", NumVFs: 4},
+		}}, vfs)).To(HaveOccurred())
+	})
+})
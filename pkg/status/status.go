@@ -0,0 +1,124 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/metrics"
+)
+
+// Status contains the current state of the safe driver load handshake
+type Status struct {
+	// name of the Node
+	Node string `json:"node"`
+	// current phase of the init container
+	Phase string `json:"phase"`
+	// time of the last phase change
+	PhaseSince time.Time `json:"phaseSince"`
+	// configuration was loaded
+	ConfigLoaded bool `json:"configLoaded"`
+	// annotation which is used for the handshake
+	Annotation string `json:"annotation,omitempty"`
+	// time when the container started to wait for the operator
+	WaitingSince *time.Time `json:"waitingSince,omitempty"`
+	// description of the last error
+	Error string `json:"error,omitempty"`
+}
+
+// Tracker tracks the state of the handshake, can be used concurrently
+type Tracker struct {
+	lock   sync.RWMutex
+	status Status
+}
+
+// NewTracker creates new Tracker for the Node
+func NewTracker(node string) *Tracker {
+	return &Tracker{status: Status{Node: node, PhaseSince: time.Now()}}
+}
+
+// SetPhase sets the current phase
+func (t *Tracker) SetPhase(phase string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status.Phase = phase
+	t.status.PhaseSince = time.Now()
+	metrics.SetPhase(phase)
+}
+
+// SetConfigLoaded marks configuration as loaded
+func (t *Tracker) SetConfigLoaded() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status.ConfigLoaded = true
+}
+
+// SetWaiting records the start of the wait for the annotation removal
+func (t *Tracker) SetWaiting(annotation string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := time.Now()
+	t.status.Annotation = annotation
+	t.status.WaitingSince = &now
+}
+
+// SetError records the error
+func (t *Tracker) SetError(err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status.Error = err.Error()
+}
+
+// Get returns copy of the current status
+func (t *Tracker) Get() Status {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.status
+}
+
+// ServeHTTP returns the current status in JSON format
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	data, err := json.Marshal(t.Get())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// ConfigLoadedCheck is a healthz.Checker which fails until configuration is loaded
+func (t *Tracker) ConfigLoadedCheck(_ *http.Request) error {
+	if !t.Get().ConfigLoaded {
+		return fmt.Errorf("configuration is not loaded")
+	}
+	return nil
+}
+
+// PhaseCheck returns healthz.Checker which fails if the current phase is not one of the phases
+func (t *Tracker) PhaseCheck(phases ...string) func(_ *http.Request) error {
+	return func(_ *http.Request) error {
+		current := t.Get().Phase
+		for _, p := range phases {
+			if current == p {
+				return nil
+			}
+		}
+		return fmt.Errorf("phase is %s", current)
+	}
+}
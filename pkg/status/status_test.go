@@ -0,0 +1,66 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package status_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/status"
+)
+
+var _ = Describe("Status tracker", func() {
+	var tracker *status.Tracker
+
+	BeforeEach(func() {
+		tracker = status.NewTracker("node1")
+	})
+
+	It("Config loaded check", func() {
+		Expect(tracker.ConfigLoadedCheck(nil)).To(HaveOccurred())
+		tracker.SetConfigLoaded()
+		Expect(tracker.ConfigLoadedCheck(nil)).NotTo(HaveOccurred())
+	})
+	It("Phase check", func() {
+		check := tracker.PhaseCheck("Wait", "Done")
+		tracker.SetPhase("LoadConfig")
+		Expect(check(nil)).To(MatchError(ContainSubstring("LoadConfig")))
+		tracker.SetPhase("Wait")
+		Expect(check(nil)).NotTo(HaveOccurred())
+	})
+	It("Status endpoint", func() {
+		tracker.SetPhase("Wait")
+		tracker.SetConfigLoaded()
+		tracker.SetWaiting("foo.bar/spam")
+		tracker.SetError(fmt.Errorf("test error"))
+
+		rec := httptest.NewRecorder()
+		tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+		s := status.Status{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &s)).NotTo(HaveOccurred())
+		Expect(s.Node).To(Equal("node1"))
+		Expect(s.Phase).To(Equal("Wait"))
+		Expect(s.ConfigLoaded).To(BeTrue())
+		Expect(s.Annotation).To(Equal("foo.bar/spam"))
+		Expect(s.WaitingSince).NotTo(BeNil())
+		Expect(s.Error).To(Equal("test error"))
+	})
+})
@@ -0,0 +1,89 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the name of the service reported in traces
+const ServiceName = "network-operator-init-container"
+
+// environment variables which configure OTLP exporter endpoint
+var endpointEnvVars = []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"}
+
+// Enabled returns true if the endpoint is set or OTLP exporter endpoint is configured with environment variables
+func Enabled(endpoint string) bool {
+	if endpoint != "" {
+		return true
+	}
+	for _, env := range endpointEnvVars {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Setup configures global TracerProvider which exports spans to the OTLP/HTTP endpoint,
+// e.g. http://localhost:4318, standard OTEL_EXPORTER_OTLP_* environment variables are supported.
+// Returns function which flushes spans and stops the provider.
+// Tracing is not configured if Enabled returns false.
+func Setup(ctx context.Context, endpoint, nodeName string) (func(context.Context) error, error) {
+	if !Enabled(endpoint) {
+		return func(context.Context) error { return nil }, nil
+	}
+	opts := []otlptracehttp.Option{}
+	if endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %v", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(ServiceName), semconv.K8SNodeName(nodeName))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tp.Shutdown, nil
+}
+
+// Tracer returns tracer of the init container
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// InjectContext returns W3C trace context of the span from ctx, e.g. {"traceparent": "00-..."},
+// returns nil if ctx has no recording span
+func InjectContext(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
@@ -0,0 +1,63 @@
+/*
+ Copyright 2023, NVIDIA CORPORATION & AFFILIATES
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/network-operator-init-container/pkg/tracing"
+)
+
+var _ = Describe("Tracing", func() {
+	It("Disabled", func() {
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		Expect(tracing.Enabled("")).To(BeFalse())
+		shutdown, err := tracing.Setup(context.Background(), "", "node1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shutdown(context.Background())).NotTo(HaveOccurred())
+	})
+	It("Enabled with environment variable", func() {
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		Expect(tracing.Enabled("")).To(BeTrue())
+	})
+	It("Export spans to the collector", func() {
+		var requests atomic.Int32
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/traces" {
+				requests.Add(1)
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer collector.Close()
+
+		ctx := context.Background()
+		shutdown, err := tracing.Setup(ctx, collector.URL, "node1")
+		Expect(err).NotTo(HaveOccurred())
+		spanCtx, span := tracing.Tracer().Start(ctx, "test")
+		Expect(tracing.InjectContext(spanCtx)).To(HaveKey("traceparent"))
+		span.End()
+		Expect(shutdown(ctx)).NotTo(HaveOccurred())
+		Expect(requests.Load()).To(BeNumerically(">", 0))
+	})
+	It("No trace context without span", func() {
+		Expect(tracing.InjectContext(context.Background())).To(BeNil())
+	})
+})